@@ -0,0 +1,410 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+)
+
+var (
+	ErrSizeMismatch  error = errors.New("c4group: file size changed while packing")
+	ErrGroupTooLarge error = errors.New("c4group: packed group too large for a child group")
+)
+
+// Builder assembles a group whose entries may be added in any order. Sizes of
+// child groups are computed automatically. File data is only read when
+// writing the group.
+type Builder struct {
+	Header Header // Entries is set automatically
+
+	name    string
+	entries []*builderEntry
+}
+
+type builderEntry struct {
+	Entry
+	open  func() (io.ReadCloser, error) // file data or raw child group data
+	group *Builder                      // child group
+
+	// source group and index of entries copied by an Editor
+	source *Group
+	index  int
+}
+
+// NewBuilder creates an empty group. The name determines the sort list used
+// by Sort.
+func NewBuilder(name string) *Builder {
+	return &Builder{name: name}
+}
+
+// AddFile adds a file entry. The entry's Size must match the length of the
+// data returned by open. If e.IsGroup is set, the data must be an
+// uncompressed child group.
+func (b *Builder) AddFile(e Entry, open func() (io.ReadCloser, error)) {
+	b.entries = append(b.entries, &builderEntry{Entry: e, open: open})
+}
+
+// AddGroup adds an empty child group and returns its Builder. The entry's
+// Size is ignored.
+func (b *Builder) AddGroup(e Entry) *Builder {
+	e.IsGroup = true
+	sub := NewBuilder(e.Filename)
+	b.entries = append(b.entries, &builderEntry{Entry: e, group: sub})
+	return sub
+}
+
+// Sort orders all entries recursively with NameLess.
+func (b *Builder) Sort() {
+	b.sortBy(GroupSortOrder(b.name))
+	for _, e := range b.entries {
+		if e.group != nil {
+			e.group.Sort()
+		}
+	}
+}
+
+// sortBy orders the entries of b, but not of child groups.
+func (b *Builder) sortBy(o *SortOrder) {
+	sort.Stable(o.sorter(len(b.entries), func(i int) string { return b.entries[i].Filename }, func(i, j int) {
+		b.entries[i], b.entries[j] = b.entries[j], b.entries[i]
+	}))
+}
+
+// Size returns the size of the group when written as child group.
+func (b *Builder) Size() int {
+	size := HeaderSize
+	for _, e := range b.entries {
+		size += EntrySize + e.size()
+	}
+	return size
+}
+
+func (e *builderEntry) size() int {
+	if e.group != nil {
+		return e.group.Size()
+	}
+	return e.Size
+}
+
+// Pack writes the group as packed group file to w.
+func (b *Builder) Pack(w io.Writer) error {
+	return b.write(NewWriter(w))
+}
+
+// PackRaw writes the group as raw group stream to w, see NewRawWriter.
+func (b *Builder) PackRaw(w io.Writer) error {
+	return b.write(NewRawWriter(w))
+}
+
+func (b *Builder) write(cw *Writer) error {
+	if err := cw.WriteHeader(b.header()); err != nil {
+		return err
+	}
+	return b.writeEntries(cw)
+}
+
+func (b *Builder) header() *Header {
+	hdr := b.Header
+	hdr.Entries = int32(len(b.entries))
+	return &hdr
+}
+
+// writeEntries writes entry headers and data and closes cw.
+func (b *Builder) writeEntries(cw *Writer) error {
+	for _, e := range b.entries {
+		entry := e.Entry
+		entry.Size = e.size()
+		if err := cw.WriteEntry(&entry); err != nil {
+			return err
+		}
+	}
+	for _, e := range b.entries {
+		if e.group != nil {
+			sub, err := cw.CreateSubGroup(e.group.header())
+			if err != nil {
+				return err
+			}
+			if err := e.group.writeEntries(sub); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.writeData(cw); err != nil {
+			return err
+		}
+	}
+	return cw.Close()
+}
+
+func (e *builderEntry) writeData(w io.Writer) error {
+	r, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	n, err := io.CopyN(w, r, int64(e.Size))
+	if err == io.EOF || n != int64(e.Size) {
+		return ErrSizeMismatch
+	}
+	return err
+}
+
+// PackOptions configures PackDir.
+type PackOptions struct {
+	Header Header // header of the resulting group, Entries is ignored
+
+	// Skip is called for each file and directory. Returning true excludes
+	// it from the group. The path is slash-separated and relative to the
+	// packed directory.
+	Skip func(path string, info fs.FileInfo) bool
+
+	// CRC enables calculation of entry checksums, see Builder.ComputeCRC.
+	CRC bool
+}
+
+// PackDir packs the directory tree at dir into a group written to w.
+// Subdirectories and packed groups become child groups. Entries are sorted
+// with NameLess. opts may be nil.
+func PackDir(w io.Writer, dir string, opts *PackOptions) error {
+	if opts == nil {
+		opts = &PackOptions{}
+	}
+	b := NewBuilder(filepath.Base(dir))
+	b.Header = opts.Header
+	if err := b.AddDir(dir, opts.Skip); err != nil {
+		return err
+	}
+	b.Sort()
+	if opts.CRC {
+		if err := b.ComputeCRC(); err != nil {
+			return err
+		}
+	}
+	return b.Pack(w)
+}
+
+// AddDir adds the contents of the directory at dir recursively. Executable
+// flags and modification times are taken from the file system. Symlinks to
+// files are followed, symlinked directories are skipped. skip may be nil, see
+// PackOptions.
+func (b *Builder) AddDir(dir string, skip func(path string, info fs.FileInfo) bool) error {
+	return b.addDir(dir, "", skip)
+}
+
+func (b *Builder) addDir(dir, rel string, skip func(path string, info fs.FileInfo) bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, de := range entries {
+		name := de.Name()
+		p := filepath.Join(dir, name)
+		// Symlinked directories may form loops.
+		if de.Type()&fs.ModeSymlink != 0 {
+			if info, err := os.Stat(p); err == nil && info.IsDir() {
+				continue
+			}
+		}
+		if err := b.addPath(p, name, path.Join(rel, name), skip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addPath adds the file or directory at p as entry with the given name.
+func (b *Builder) addPath(p, name, rel string, skip func(path string, info fs.FileInfo) bool) error {
+	// Follow symlinks, addDir skips those to directories.
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	if skip != nil && skip(rel, info) {
+		return nil
+	}
+	e := Entry{
+		Filename:   name,
+		Mtime:      info.ModTime(),
+		Executable: info.Mode()&0111 != 0 && !info.IsDir(),
+	}
+	if info.IsDir() {
+		sub := b.AddGroup(e)
+		return sub.addDir(p, rel, skip)
+	}
+	size, packed, err := packedGroupSize(p)
+	if err != nil {
+		return err
+	}
+	if packed {
+		// Child groups are stored uncompressed.
+		e.IsGroup = true
+		e.Size = size
+		b.AddFile(e, func() (io.ReadCloser, error) { return openPackedGroup(p) })
+	} else {
+		e.Size = int(info.Size())
+		b.AddFile(e, func() (io.ReadCloser, error) { return os.Open(p) })
+	}
+	return nil
+}
+
+// AddFS adds the contents of the directory dir in fsys recursively, e.g. to
+// copy parts of another group opened with NewFS. Directories become child
+// groups. Packed groups become child groups as well if the files are
+// seekable, like with os.DirFS. Modification times and executable flags are
+// taken from the entries of groups and from the file mode otherwise.
+func (b *Builder) AddFS(fsys fs.FS, dir string) error {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return err
+	}
+	for _, de := range entries {
+		p := path.Join(dir, de.Name())
+		info, err := de.Info()
+		if err != nil {
+			return err
+		}
+		e := Entry{
+			Filename:   de.Name(),
+			Mtime:      info.ModTime(),
+			Executable: info.Mode()&0111 != 0 && !info.IsDir(),
+		}
+		if entry, ok := info.Sys().(*Entry); ok {
+			e.Executable = entry.Executable
+		}
+		if info.IsDir() {
+			if err := b.AddGroup(e).AddFS(fsys, p); err != nil {
+				return err
+			}
+			continue
+		}
+		size, packed, err := packedFSSize(fsys, p)
+		if err != nil {
+			return err
+		}
+		if packed {
+			e.IsGroup = true
+			e.Size = size
+			b.AddFile(e, func() (io.ReadCloser, error) {
+				f, err := fsys.Open(p)
+				if err != nil {
+					return nil, err
+				}
+				return newPackedGroupReader(f)
+			})
+		} else {
+			e.Size = int(info.Size())
+			b.AddFile(e, func() (io.ReadCloser, error) { return fsys.Open(p) })
+		}
+	}
+	return nil
+}
+
+// packedFSSize is packedGroupSize for files in fsys. Files which can't seek
+// are never packed groups.
+func packedFSSize(fsys fs.FS, name string) (size int, packed bool, err error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	rs, ok := f.(io.ReadSeeker)
+	if !ok {
+		return 0, false, nil
+	}
+	return packedSize(rs)
+}
+
+// packedGroupSize checks whether the file is a packed group and returns its
+// uncompressed size.
+func packedGroupSize(filename string) (size int, packed bool, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+	return packedSize(f)
+}
+
+// packedSize is packedGroupSize for an open file. The size is computed from
+// the group headers as the gzip trailer only contains it modulo 2^32.
+func packedSize(f io.ReadSeeker) (size int, packed bool, err error) {
+	var magic [2]byte
+	if _, err := io.ReadFull(f, magic[:]); err != nil || magic[0] != C4GzMagic1 || magic[1] != C4GzMagic2 {
+		return 0, false, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, false, err
+	}
+	r, err := NewReader(f)
+	if err != nil {
+		// Only the magic bytes match.
+		return 0, false, nil
+	}
+	defer r.Close()
+	dataOffset := HeaderSize + int64(len(r.entries))*EntrySize
+	end := dataOffset
+	for _, e := range r.entries {
+		if e.Offset < 0 || e.Size < 0 {
+			return 0, true, ErrEntryInvalid
+		}
+		if n := dataOffset + int64(e.Offset) + int64(e.Size); n > end {
+			end = n
+		}
+	}
+	// Entry sizes are 32 bit.
+	if end > math.MaxInt32 {
+		return 0, true, ErrGroupTooLarge
+	}
+	return int(end), true, nil
+}
+
+// packedGroupReader decompresses a packed group file.
+type packedGroupReader struct {
+	*gzip.Reader
+	f io.Closer
+}
+
+func openPackedGroup(filename string) (io.ReadCloser, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	return newPackedGroupReader(f)
+}
+
+// newPackedGroupReader decompresses the packed group f. f is closed with the
+// returned reader.
+func newPackedGroupReader(f io.ReadCloser) (io.ReadCloser, error) {
+	gz, err := gzip.NewReader(&magicBytesReader{r: bufio.NewReader(f)})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &packedGroupReader{Reader: gz, f: f}, nil
+}
+
+func (r *packedGroupReader) Close() error {
+	r.Reader.Close()
+	return r.f.Close()
+}
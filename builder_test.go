@@ -0,0 +1,222 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestDir creates files below dir, including parent directories.
+func writeTestDir(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPackDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Test.ocs")
+	writeTestDir(t, dir, map[string]string{
+		"Map.c":                             "func InitializeMap() {}",
+		"Script.c":                          "func Initialize() {}",
+		"Scenario.txt":                      "[Head]\n",
+		"Objects.ocd/Rock.ocd/DefCore.txt":  "[DefCore]\nid=Rock\n",
+		"Objects.ocd/Rock.ocd/Script.c":     "#appendto Rock\n",
+		"Objects.ocd/Rock.ocd/Graphics.png": "PNG",
+	})
+	mtime := time.Unix(1500000000, 0)
+	if err := os.Chtimes(filepath.Join(dir, "Map.c"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(dir, "Script.c"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pack Objects.ocd separately to check that packed child groups are
+	// included uncompressed.
+	objects := filepath.Join(dir, "Objects.ocd")
+	var packed bytes.Buffer
+	if err := PackDir(&packed, objects, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(objects); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(objects, packed.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err := PackDir(&buf, dir, &PackOptions{Header: Header{Author: "Tester"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Header.Author != "Tester" {
+		t.Errorf("unexpected author %q", g.Header.Author)
+	}
+	var names []string
+	for _, e := range g.Entries {
+		names = append(names, e.Filename)
+	}
+	want := []string{"Scenario.txt", "Objects.ocd", "Script.c", "Map.c"}
+	if len(names) != len(want) {
+		t.Fatalf("got entries %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got entries %v, want %v", names, want)
+		}
+	}
+	if !g.Entries[2].Executable || g.Entries[3].Executable {
+		t.Error("wrong executable flags")
+	}
+	if !g.Entries[3].Mtime.Equal(mtime) {
+		t.Errorf("Map.c: got mtime %v, want %v", g.Entries[3].Mtime, mtime)
+	}
+	rock, err := g.OpenGroup("Objects.ocd/Rock.ocd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rock.Entries[0].Filename != "Graphics.png" || rock.Entries[1].Filename != "DefCore.txt" {
+		t.Errorf("Rock.ocd: unexpected order %+v", rock.Entries)
+	}
+	if got := readTestEntry(t, g, "Objects.ocd/Rock.ocd/Script.c"); string(got) != "#appendto Rock\n" {
+		t.Errorf("Script.c: got %q", got)
+	}
+}
+
+func TestAddFS(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDir(t, dir, map[string]string{
+		"Scenario.txt":                     "[Head]\n",
+		"Objects.ocd/Rock.ocd/DefCore.txt": "[DefCore]\nid=Rock\n",
+	})
+	objects := filepath.Join(dir, "Objects.ocd")
+	var packed bytes.Buffer
+	if err := PackDir(&packed, objects, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(objects); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(objects, packed.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Copy from a directory, then from the resulting group.
+	b := NewBuilder("Test.ocs")
+	if err := b.AddFS(os.DirFS(dir), "."); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := b.Pack(&buf); err != nil {
+		t.Fatal(err)
+	}
+	g, err := OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = NewBuilder("Objects.ocd")
+	if err := b.AddFS(NewFS(g), "Objects.ocd"); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := b.Pack(&buf); err != nil {
+		t.Fatal(err)
+	}
+	g, err = OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestEntry(t, g, "Rock.ocd/DefCore.txt"); string(got) != "[DefCore]\nid=Rock\n" {
+		t.Errorf("DefCore.txt: got %q", got)
+	}
+}
+
+func TestPackDirSymlinks(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Test.ocs")
+	writeTestDir(t, dir, map[string]string{
+		"Script.c":         "func Initialize() {}",
+		"Sub.ocd/Data.txt": "data",
+		// Magic bytes without a group header.
+		"Fake.ocd": "\x1e\x8cfake",
+	})
+	if err := os.Symlink("..", filepath.Join(dir, "Sub.ocd", "Loop.ocd")); err != nil {
+		t.Skip(err)
+	}
+	if err := os.Symlink("Script.c", filepath.Join(dir, "Link.c")); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := PackDir(&buf, dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	g, err := OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Lookup("Sub.ocd/Loop.ocd"); err != ErrNotFound {
+		t.Errorf("Loop.ocd: expected ErrNotFound, got %v", err)
+	}
+	if got := readTestEntry(t, g, "Link.c"); string(got) != "func Initialize() {}" {
+		t.Errorf("Link.c: got %q", got)
+	}
+	e, err := g.Lookup("Fake.ocd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.IsGroup || e.Size != 6 {
+		t.Errorf("Fake.ocd: got %+v", e)
+	}
+}
+
+func TestPackedSizeTooLarge(t *testing.T) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&magicBytesWriter{w: &buf})
+	h := header{Ver1: C4GroupFileVer1, Ver2: C4GroupFileVer2, Entries: 2}
+	copy(h.ID[:], C4GroupFileID)
+	binary.Write(gz, binary.LittleEndian, &h)
+	// Together, the entries exceed the 32 bit size of a child group.
+	e := entry{Size: math.MaxInt32 - 100}
+	copy(e.Filename[:], "Big1.txt")
+	binary.Write(gz, binary.LittleEndian, &e)
+	e.Offset = e.Size
+	copy(e.Filename[:], "Big2.txt")
+	binary.Write(gz, binary.LittleEndian, &e)
+	gz.Close()
+
+	_, packed, err := packedSize(bytes.NewReader(buf.Bytes()))
+	if !packed || err != ErrGroupTooLarge {
+		t.Errorf("got packed %v, error %v, want ErrGroupTooLarge", packed, err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/lluchs/c4group-go/defs"
+)
+
+// bundleAction writes a standalone copy of a scenario with all definitions it
+// needs from the given packs embedded.
+func bundleAction(args []string) int {
+	flags := flag.NewFlagSet("bundle", flag.ContinueOnError)
+	dryRun := flags.Bool("n", false, "only print the definitions that would be embedded")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "bundle [-n] <scenario> <output> <definitions>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 3 {
+		flags.Usage()
+		return 2
+	}
+	scenarioName, output := flags.Arg(0), flags.Arg(1)
+
+	scenario, closer, err := openGroupFS(scenarioName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closer.Close()
+	var packs []defs.Pack
+	for _, name := range flags.Args()[2:] {
+		fsys, closer, err := openGroupFS(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer closer.Close()
+		packs = append(packs, defs.Pack{Name: filepath.Base(name), FS: fsys})
+	}
+
+	name := filepath.Base(scenarioName)
+	var res *defs.Resolution
+	if *dryRun {
+		res, err = defs.Resolve(name, scenario, packs)
+	} else {
+		res, err = exportScenario(output, name, scenario, packs)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, err := range res.Errors {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+	for _, id := range res.Missing {
+		fmt.Fprintln(os.Stderr, "warning: missing definition", id)
+	}
+	for _, def := range res.Defs {
+		fmt.Printf("%s\t%s\n", def.ID, def.Path)
+	}
+	return 0
+}
+
+// exportScenario writes the bundled scenario to the file output.
+func exportScenario(output, name string, scenario fs.FS, packs []defs.Pack) (res *defs.Resolution, err error) {
+	file, err := os.Create(output)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(output)
+		}
+	}()
+	w := bufio.NewWriter(file)
+	if res, err = defs.Export(w, name, scenario, packs); err != nil {
+		return nil, err
+	}
+	if err = w.Flush(); err != nil {
+		return nil, err
+	}
+	return res, file.Close()
+}
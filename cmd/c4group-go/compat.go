@@ -0,0 +1,811 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lluchs/c4group-go"
+)
+
+// This file implements the command line interface of OpenClonk's c4group:
+//
+//	c4group-go [options] <group> <commands>
+//
+// The group may be a packed group file or an unpacked directory.
+
+// c4groupCommands maps commands to their minimum and maximum number of
+// arguments. -1 means unlimited.
+var c4groupCommands = map[string][2]int{
+	"-l": {0, 1},
+	"-a": {1, -1},
+	"-m": {1, -1},
+	"-e": {0, -1},
+	"-v": {1, -1},
+	"-d": {1, -1},
+	"-r": {2, 2},
+	"-s": {0, 1},
+	"-p": {0, 0},
+	"-t": {1, 1},
+	"-u": {0, 0},
+	"-x": {0, 0},
+}
+
+type c4groupCommand struct {
+	name string
+	args []string
+}
+
+// c4groupTool executes commands on a single group.
+type c4groupTool struct {
+	verbose, recursive, yes bool
+	group                   string
+}
+
+func printC4groupUsage() {
+	fmt.Println("Usage:", os.Args[0], "[options] <group> <commands>")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  -v  verbose")
+	fmt.Println("  -r  recursive")
+	fmt.Println("  -y  yes to all prompts")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  -l [wildcard]         list")
+	fmt.Println("  -a <files>            add (wildcards ok)")
+	fmt.Println("  -m <files>            move (wildcards ok)")
+	fmt.Println("  -e [wildcards]        extract")
+	fmt.Println("  -v <wildcards>        view")
+	fmt.Println("  -d <wildcards>        delete")
+	fmt.Println("  -r <entry> <new name> rename")
+	fmt.Println("  -s [sort list]        sort")
+	fmt.Println("  -p                    pack")
+	fmt.Println("  -t <file>             pack to target file")
+	fmt.Println("  -u                    unpack")
+	fmt.Println("  -x                    explode")
+}
+
+// c4groupMain runs the c4group-compatible interface. It returns 0 on success
+// and 1 on failure, like c4group.
+func c4groupMain(args []string) int {
+	var tool c4groupTool
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		for _, c := range args[0][1:] {
+			switch c {
+			case 'v':
+				tool.verbose = true
+			case 'r':
+				tool.recursive = true
+			case 'y':
+				tool.yes = true
+			default:
+				fmt.Fprintf(os.Stderr, "Unknown option %s\n", args[0])
+				return 1
+			}
+		}
+		args = args[1:]
+	}
+	if len(args) < 2 {
+		printC4groupUsage()
+		return 1
+	}
+	pattern := args[0]
+	commands, err := parseC4groupCommands(args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	groups, err := filepath.Glob(pattern)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if len(groups) == 0 {
+		// Adding to a non-existing group creates it.
+		if _, err := os.Stat(pattern); os.IsNotExist(err) && (commands[0].name == "-a" || commands[0].name == "-m") {
+			groups = []string{pattern}
+		} else {
+			fmt.Fprintf(os.Stderr, "Group %s not found\n", pattern)
+			return 1
+		}
+	}
+
+	result := 0
+	for _, group := range groups {
+		tool.group = group
+		if tool.verbose {
+			fmt.Printf("Group: %s\n", group)
+		}
+		for _, cmd := range commands {
+			if err := tool.run(cmd); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s %s: %s\n", group, cmd.name, strings.Join(cmd.args, " "), err)
+				result = 1
+				break
+			}
+		}
+	}
+	return result
+}
+
+func parseC4groupCommands(args []string) ([]c4groupCommand, error) {
+	var commands []c4groupCommand
+	for len(args) > 0 {
+		cmd := c4groupCommand{name: args[0]}
+		count, ok := c4groupCommands[cmd.name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown command %s", cmd.name)
+		}
+		args = args[1:]
+		for len(args) > 0 && !strings.HasPrefix(args[0], "-") && (count[1] < 0 || len(cmd.args) < count[1]) {
+			cmd.args = append(cmd.args, args[0])
+			args = args[1:]
+		}
+		if len(cmd.args) < count[0] {
+			return nil, fmt.Errorf("Missing argument for command %s", cmd.name)
+		}
+		commands = append(commands, cmd)
+	}
+	return commands, nil
+}
+
+func (t *c4groupTool) run(cmd c4groupCommand) error {
+	info, err := os.Stat(t.group)
+	if os.IsNotExist(err) && (cmd.name == "-a" || cmd.name == "-m") {
+		if err := t.create(); err != nil {
+			return err
+		}
+		info, err = os.Stat(t.group)
+	}
+	if err != nil {
+		return err
+	}
+	dir := info.IsDir()
+
+	switch cmd.name {
+	case "-l":
+		pattern := "*"
+		if len(cmd.args) > 0 {
+			pattern = cmd.args[0]
+		}
+		return t.list(pattern)
+	case "-a", "-m":
+		return t.add(dir, cmd.args, cmd.name == "-m")
+	case "-e":
+		if len(cmd.args) == 0 {
+			return t.extract(dir, []string{"*"})
+		}
+		return t.extract(dir, cmd.args)
+	case "-v":
+		return t.view(dir, cmd.args)
+	case "-d":
+		return t.delete(dir, cmd.args)
+	case "-r":
+		return t.rename(dir, cmd.args[0], cmd.args[1])
+	case "-s":
+		var list string
+		if len(cmd.args) > 0 {
+			list = cmd.args[0]
+		}
+		return t.sort(dir, list)
+	case "-p":
+		if !dir {
+			return nil
+		}
+		return t.pack()
+	case "-t":
+		return t.packTo(dir, cmd.args[0])
+	case "-u":
+		if dir {
+			return nil
+		}
+		return unpackFile(t.group, true)
+	case "-x":
+		if dir {
+			return explodeDir(t.group)
+		}
+		return unpackFile(t.group, false)
+	}
+	return nil
+}
+
+// create writes an empty packed group.
+func (t *c4groupTool) create() error {
+	if t.verbose {
+		fmt.Printf("Creating %s...\n", t.group)
+	}
+	f, err := os.OpenFile(t.group, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if err := c4group.NewBuilder(filepath.Base(t.group)).Pack(f); err != nil {
+		f.Close()
+		os.Remove(t.group)
+		return err
+	}
+	return f.Close()
+}
+
+// confirm asks whether an existing file may be replaced. Without a terminal,
+// only -y allows overwriting.
+func (t *c4groupTool) confirm(filename string) error {
+	if _, err := os.Lstat(filename); os.IsNotExist(err) || t.yes {
+		return nil
+	}
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		fmt.Printf("%s already exists. Overwrite? (y/n) ", filename)
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.HasPrefix(strings.ToLower(answer), "y") {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s already exists", filename)
+}
+
+// list prints entries matching pattern.
+func (t *c4groupTool) list(pattern string) error {
+	listing, err := openListing(t.group)
+	if err != nil {
+		return err
+	}
+	defer listing.Close()
+	if hdr := listing.Header; hdr != nil {
+		if hdr.Author != "" {
+			fmt.Printf("Author: %s\n", hdr.Author)
+		}
+		if hdr.Ctime.Unix() > 0 {
+			fmt.Printf("Created: %s\n", hdr.Ctime)
+		}
+	}
+	w := newListWriter(os.Stdout)
+	var files, bytes int
+	err = listing.walk(t.recursive, func(e listEntry) error {
+		if c4group.WildcardMatch(pattern, path.Base(e.Path)) {
+			printListEntry(w, e)
+			files++
+			bytes += e.Size
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	w.Flush()
+	fmt.Printf("%d Entries, %d Bytes\n", files, bytes)
+	return nil
+}
+
+// matchEntries returns the names of all entries matching any of the
+// patterns. It fails if a pattern doesn't match anything.
+func (t *c4groupTool) matchEntries(dir bool, patterns []string) ([]string, error) {
+	var names []string
+	if dir {
+		entries, err := os.ReadDir(t.group)
+		if err != nil {
+			return nil, err
+		}
+		for _, de := range entries {
+			names = append(names, de.Name())
+		}
+	} else {
+		file, err := os.Open(t.group)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader, err := c4group.NewReader(bufio.NewReader(file))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		for _, e := range reader.Entries {
+			names = append(names, e.Filename)
+		}
+	}
+	var matches []string
+	for _, pattern := range patterns {
+		found := false
+		for _, name := range names {
+			if c4group.WildcardMatch(pattern, name) {
+				matches = append(matches, name)
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no entries matching %s", pattern)
+		}
+	}
+	return matches, nil
+}
+
+// add adds files from disk, replacing existing entries. With move, the files
+// are deleted afterwards.
+func (t *c4groupTool) add(dir bool, patterns []string, move bool) error {
+	var files []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("no files matching %s", pattern)
+		}
+		files = append(files, matches...)
+	}
+	for _, file := range files {
+		if t.verbose {
+			if move {
+				fmt.Printf("Moving %s...\n", file)
+			} else {
+				fmt.Printf("Adding %s...\n", file)
+			}
+		}
+	}
+
+	if dir {
+		for _, file := range files {
+			target := filepath.Join(t.group, filepath.Base(file))
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+			if move {
+				if err := os.Rename(file, target); err == nil {
+					continue
+				}
+			}
+			if err := copyPath(file, target); err != nil {
+				return err
+			}
+			if move {
+				if err := os.RemoveAll(file); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	err := editGroup(t.group, func(ed *c4group.Editor) error {
+		for _, file := range files {
+			name := filepath.Base(file)
+			if err := ed.Delete(name); err != nil && err != c4group.ErrNotFound {
+				return err
+			}
+			if err := ed.AddPath(name, file); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+		}
+		return nil
+	})
+	if err != nil || !move {
+		return err
+	}
+	for _, file := range files {
+		if err := os.RemoveAll(file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyPath copies a file or directory tree.
+func copyPath(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if err := os.Mkdir(dst, 0755); err != nil {
+			return err
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, de := range entries {
+			if err := copyPath(filepath.Join(src, de.Name()), filepath.Join(dst, de.Name())); err != nil {
+				return err
+			}
+		}
+	} else {
+		in, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		if err := writeFile(dst, info.Mode().Perm(), in); err != nil {
+			return err
+		}
+	}
+	return os.Chtimes(dst, info.ModTime(), info.ModTime())
+}
+
+// writeFile creates a new file with the data from r.
+func writeFile(filename string, perm os.FileMode, r io.Reader) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// extract writes matching entries to the working directory. Child groups
+// are written as packed groups.
+func (t *c4groupTool) extract(dir bool, patterns []string) error {
+	names, err := t.matchEntries(dir, patterns)
+	if err != nil {
+		return err
+	}
+	var g *c4group.Group
+	if !dir {
+		file, err := os.Open(t.group)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if g, err = c4group.OpenReaderAt(file, info.Size()); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if err := c4group.CheckFilename(name); err != nil {
+			return err
+		}
+		if t.verbose {
+			fmt.Printf("Extracting %s...\n", name)
+		}
+		if err := t.confirm(name); err != nil {
+			return err
+		}
+		if err := os.RemoveAll(name); err != nil {
+			return err
+		}
+		if dir {
+			err = extractFromDir(filepath.Join(t.group, name), name)
+		} else {
+			err = extractFromGroup(g, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractFromDir(src, dst string) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return copyPath(src, dst)
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := c4group.PackDir(w, src, nil); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func extractFromGroup(g *c4group.Group, name string) error {
+	entry, err := g.Lookup(name)
+	if err != nil {
+		return err
+	}
+	var perm os.FileMode = 0644
+	if entry.Executable {
+		perm = 0755
+	}
+	var data io.Reader
+	if entry.IsGroup {
+		sub, err := g.OpenGroup(name)
+		if err != nil {
+			return err
+		}
+		pr, pw := io.Pipe()
+		defer pr.Close()
+		go func() {
+			bw := bufio.NewWriter(pw)
+			err := c4group.NewEditor(name, sub).Pack(bw)
+			if err == nil {
+				err = bw.Flush()
+			}
+			pw.CloseWithError(err)
+		}()
+		data = pr
+	} else {
+		if data, err = g.Open(name); err != nil {
+			return err
+		}
+	}
+	if err := writeFile(name, perm, data); err != nil {
+		return err
+	}
+	if entry.Mtime.Unix() > 0 {
+		return os.Chtimes(name, entry.Mtime, entry.Mtime)
+	}
+	return nil
+}
+
+// view prints the contents of matching files.
+func (t *c4groupTool) view(dir bool, patterns []string) error {
+	names, err := t.matchEntries(dir, patterns)
+	if err != nil {
+		return err
+	}
+	if dir {
+		for _, name := range names {
+			if err := printFile(filepath.Join(t.group, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	file, err := os.Open(t.group)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	g, err := c4group.OpenReaderAt(file, info.Size())
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		entry, err := g.Lookup(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if entry.IsGroup {
+			continue
+		}
+		data, err := g.Open(name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(os.Stdout, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printFile(filename string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil || info.IsDir() {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, f)
+	return err
+}
+
+// delete removes matching entries.
+func (t *c4groupTool) delete(dir bool, patterns []string) error {
+	names, err := t.matchEntries(dir, patterns)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if t.verbose {
+			fmt.Printf("Deleting %s...\n", name)
+		}
+	}
+	if dir {
+		for _, name := range names {
+			if err := os.RemoveAll(filepath.Join(t.group, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return editGroup(t.group, func(ed *c4group.Editor) error {
+		for _, name := range names {
+			// Patterns may match the same entry more than once.
+			if err := ed.Delete(name); err != nil && err != c4group.ErrNotFound {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (t *c4groupTool) rename(dir bool, name, newName string) error {
+	if t.verbose {
+		fmt.Printf("Renaming %s to %s...\n", name, newName)
+	}
+	if !dir {
+		return editGroup(t.group, func(ed *c4group.Editor) error {
+			return ed.Rename(name, newName)
+		})
+	}
+	if err := c4group.CheckFilename(name); err != nil {
+		return err
+	}
+	if err := c4group.CheckFilename(newName); err != nil {
+		return err
+	}
+	target := filepath.Join(t.group, newName)
+	if _, err := os.Lstat(target); err == nil {
+		return c4group.ErrEntryExists
+	}
+	return os.Rename(filepath.Join(t.group, name), target)
+}
+
+// sort reorders entries of packed groups. Directories have no order.
+func (t *c4groupTool) sort(dir bool, list string) error {
+	if dir {
+		return nil
+	}
+	return editGroup(t.group, func(ed *c4group.Editor) error {
+		return ed.Sort("", list)
+	})
+}
+
+// pack replaces the directory with a packed group.
+func (t *c4groupTool) pack() error {
+	if t.verbose {
+		fmt.Printf("Packing %s...\n", t.group)
+	}
+	tmp, err := packToTemp(t.group)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(t.group); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, t.group)
+}
+
+// packTo writes the group as packed group to target.
+func (t *c4groupTool) packTo(dir bool, target string) error {
+	if t.verbose {
+		fmt.Printf("Packing %s to %s...\n", t.group, target)
+	}
+	if err := t.confirm(target); err != nil {
+		return err
+	}
+	if !dir {
+		in, err := os.Open(t.group)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		return writeFile(target, 0644, in)
+	}
+	tmp, err := packToTemp(t.group)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(target); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, target)
+}
+
+// packToTemp packs the directory into a temporary file next to it.
+func packToTemp(dir string) (name string, err error) {
+	dir = filepath.Clean(dir)
+	tmp, err := os.CreateTemp(filepath.Dir(dir), "."+filepath.Base(dir)+".*")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	w := bufio.NewWriter(tmp)
+	if err = c4group.PackDir(w, dir, nil); err != nil {
+		return "", err
+	}
+	if err = w.Flush(); err != nil {
+		return "", err
+	}
+	if err = tmp.Chmod(0644); err != nil {
+		return "", err
+	}
+	return tmp.Name(), tmp.Close()
+}
+
+// unpackFile replaces a packed group with a directory. With packChildGroups,
+// child groups stay packed.
+func unpackFile(filename string, packChildGroups bool) (err error) {
+	filename = filepath.Clean(filename)
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader, err := c4group.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	tmp, err := os.MkdirTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(tmp)
+		}
+	}()
+	if err = c4group.Extract(reader, tmp, &c4group.ExtractOptions{PackChildGroups: packChildGroups}); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp, 0755); err != nil {
+		return err
+	}
+	if err = os.Remove(filename); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
+
+// explodeDir unpacks all packed groups in the directory tree.
+func explodeDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, de := range entries {
+		p := filepath.Join(dir, de.Name())
+		if de.IsDir() {
+			err = explodeDir(p)
+		} else if isPackedGroup(p) {
+			err = unpackFile(p, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isPackedGroup checks for the magic bytes of packed groups.
+func isPackedGroup(filename string) bool {
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	var magic [2]byte
+	if _, err := io.ReadFull(f, magic[:]); err != nil {
+		return false
+	}
+	return magic[0] == c4group.C4GzMagic1 && magic[1] == c4group.C4GzMagic2
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lluchs/c4group-go"
+)
+
+func TestParseC4groupCommands(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []c4groupCommand
+		err  string
+	}{
+		{[]string{"-l"}, []c4groupCommand{{name: "-l"}}, ""},
+		{[]string{"-l", "*.c"}, []c4groupCommand{{name: "-l", args: []string{"*.c"}}}, ""},
+		{[]string{"-l", "*.c", "*.txt"}, nil, "Unknown command *.txt"},
+		{[]string{"-a", "a", "b", "-l"}, []c4groupCommand{{name: "-a", args: []string{"a", "b"}}, {name: "-l"}}, ""},
+		{[]string{"-e"}, []c4groupCommand{{name: "-e"}}, ""},
+		{[]string{"-r", "a", "b"}, []c4groupCommand{{name: "-r", args: []string{"a", "b"}}}, ""},
+		{[]string{"-r", "a"}, nil, "Missing argument for command -r"},
+		{[]string{"-d"}, nil, "Missing argument for command -d"},
+		{[]string{"-t", "out.ocd", "-x"}, []c4groupCommand{{name: "-t", args: []string{"out.ocd"}}, {name: "-x"}}, ""},
+		{[]string{"-p", "extra"}, nil, "Unknown command extra"},
+		{[]string{"-q"}, nil, "Unknown command -q"},
+	}
+	for _, test := range tests {
+		got, err := parseC4groupCommands(test.args)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%v: got error %v, want %q", test.args, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%v: got %+v, want %+v", test.args, got, test.want)
+		}
+	}
+}
+
+// runC4group runs c4groupMain in dir and returns the exit code and the
+// output on stdout.
+func runC4group(t *testing.T, dir string, args ...string) (int, string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	out, err := ioutil.TempFile(t.TempDir(), "stdout")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = out, out
+	code := c4groupMain(args)
+	os.Stdout, os.Stderr = stdout, stderr
+
+	data, err := ioutil.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, string(data)
+}
+
+// openTestGroup opens a packed group file.
+func openTestGroup(t *testing.T, filename string) *c4group.Group {
+	t.Helper()
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := c4group.OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// entryNames returns the filenames of the entries of g.
+func entryNames(g *c4group.Group) []string {
+	var names []string
+	for _, e := range g.Entries {
+		names = append(names, e.Filename)
+	}
+	return names
+}
+
+func TestC4groupMainErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := [][]string{
+		{},
+		{"Test.ocd"},
+		{"-q", "Test.ocd", "-l"},
+		{"Test.ocd", "-q"},
+		{"Missing.ocd", "-l"},
+	}
+	for _, args := range tests {
+		if code, out := runC4group(t, dir, args...); code != 1 {
+			t.Errorf("%v: got exit code %d, want 1\n%s", args, code, out)
+		}
+	}
+}
+
+func TestC4groupCommands(t *testing.T) {
+	files := map[string]string{
+		"Script.c":              "func Initialize() {}",
+		"Title.txt":             "Test",
+		"Sub.ocd/DefCore.txt":   "[DefCore]\nid=Sub\n",
+		"Sub.ocd/Nested.ocd/x":  "x",
+		"Sub.ocd/Graphics.png":  "PNG",
+		"Sub.ocd/Nested.ocd/yy": "yy",
+	}
+	// setup creates Test.ocd in a new directory, either packed or as
+	// directory, and the file New.txt next to it.
+	setup := func(t *testing.T, packed bool) string {
+		dir := t.TempDir()
+		group := filepath.Join(dir, "Test.ocd")
+		writeTestDir(t, group, files)
+		if packed {
+			packTestDir(t, group)
+		}
+		writeTestDir(t, dir, map[string]string{"New.txt": "new"})
+		return dir
+	}
+
+	tests := []struct {
+		name   string
+		packed bool
+		args   []string
+		check  func(t *testing.T, dir, out string)
+	}{
+		{"list", true, []string{"Test.ocd", "-l"}, func(t *testing.T, dir, out string) {
+			for _, s := range []string{"Script.c", "Sub.ocd", "(Group)", "3 Entries"} {
+				if !strings.Contains(out, s) {
+					t.Errorf("missing %q in output:\n%s", s, out)
+				}
+			}
+		}},
+		{"list recursive", true, []string{"-r", "Test.ocd", "-l", "*.txt"}, func(t *testing.T, dir, out string) {
+			if !strings.Contains(out, "Sub.ocd/DefCore.txt") || !strings.Contains(out, "2 Entries") {
+				t.Errorf("unexpected output:\n%s", out)
+			}
+		}},
+		{"list directory", false, []string{"Test.ocd", "-l", "*.c"}, func(t *testing.T, dir, out string) {
+			if !strings.Contains(out, "Script.c") || !strings.Contains(out, "1 Entries") {
+				t.Errorf("unexpected output:\n%s", out)
+			}
+		}},
+		{"add", true, []string{"Test.ocd", "-a", "New.txt"}, func(t *testing.T, dir, out string) {
+			g := openTestGroup(t, filepath.Join(dir, "Test.ocd"))
+			if _, err := g.Lookup("New.txt"); err != nil {
+				t.Errorf("New.txt: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "New.txt")); err != nil {
+				t.Errorf("New.txt was removed: %v", err)
+			}
+		}},
+		{"move", false, []string{"Test.ocd", "-m", "New.txt"}, func(t *testing.T, dir, out string) {
+			if _, err := os.Stat(filepath.Join(dir, "Test.ocd", "New.txt")); err != nil {
+				t.Errorf("New.txt: %v", err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "New.txt")); !os.IsNotExist(err) {
+				t.Errorf("New.txt wasn't removed: %v", err)
+			}
+		}},
+		{"extract", true, []string{"Test.ocd", "-e", "*.c", "Sub.ocd"}, func(t *testing.T, dir, out string) {
+			if data, err := ioutil.ReadFile(filepath.Join(dir, "Script.c")); err != nil || string(data) != files["Script.c"] {
+				t.Errorf("Script.c: got %q, %v", data, err)
+			}
+			sub := openTestGroup(t, filepath.Join(dir, "Sub.ocd"))
+			if len(sub.Entries) != 3 {
+				t.Errorf("Sub.ocd: got entries %v", entryNames(sub))
+			}
+		}},
+		{"view", true, []string{"Test.ocd", "-v", "Title.txt"}, func(t *testing.T, dir, out string) {
+			if out != "Test" {
+				t.Errorf("got %q", out)
+			}
+		}},
+		{"delete", true, []string{"Test.ocd", "-d", "*.c"}, func(t *testing.T, dir, out string) {
+			g := openTestGroup(t, filepath.Join(dir, "Test.ocd"))
+			if _, err := g.Lookup("Script.c"); err != c4group.ErrNotFound {
+				t.Errorf("Script.c: expected ErrNotFound, got %v", err)
+			}
+		}},
+		{"rename", true, []string{"Test.ocd", "-r", "Title.txt", "Name.txt"}, func(t *testing.T, dir, out string) {
+			g := openTestGroup(t, filepath.Join(dir, "Test.ocd"))
+			if _, err := g.Lookup("Name.txt"); err != nil {
+				t.Errorf("Name.txt: %v", err)
+			}
+		}},
+		{"sort", true, []string{"Test.ocd", "-s", "Title.txt|*.c"}, func(t *testing.T, dir, out string) {
+			g := openTestGroup(t, filepath.Join(dir, "Test.ocd"))
+			want := []string{"Title.txt", "Script.c", "Sub.ocd"}
+			if names := entryNames(g); !reflect.DeepEqual(names, want) {
+				t.Errorf("got %v, want %v", names, want)
+			}
+		}},
+		{"pack", false, []string{"Test.ocd", "-p"}, func(t *testing.T, dir, out string) {
+			g := openTestGroup(t, filepath.Join(dir, "Test.ocd"))
+			if _, err := g.Lookup("Sub.ocd/Nested.ocd/yy"); err != nil {
+				t.Error(err)
+			}
+		}},
+		{"pack to", false, []string{"Test.ocd", "-t", "Out.ocd"}, func(t *testing.T, dir, out string) {
+			g := openTestGroup(t, filepath.Join(dir, "Out.ocd"))
+			if _, err := g.Lookup("Script.c"); err != nil {
+				t.Error(err)
+			}
+			if info, err := os.Stat(filepath.Join(dir, "Test.ocd")); err != nil || !info.IsDir() {
+				t.Errorf("Test.ocd: got %v, %v", info, err)
+			}
+		}},
+		{"unpack", true, []string{"Test.ocd", "-u"}, func(t *testing.T, dir, out string) {
+			info, err := os.Stat(filepath.Join(dir, "Test.ocd", "Sub.ocd"))
+			if err != nil || info.IsDir() {
+				t.Errorf("Sub.ocd should stay packed: %v, %v", info, err)
+			}
+		}},
+		{"explode", true, []string{"Test.ocd", "-x"}, func(t *testing.T, dir, out string) {
+			data, err := ioutil.ReadFile(filepath.Join(dir, "Test.ocd", "Sub.ocd", "Nested.ocd", "yy"))
+			if err != nil || string(data) != "yy" {
+				t.Errorf("Sub.ocd/Nested.ocd/yy: got %q, %v", data, err)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := setup(t, test.packed)
+			code, out := runC4group(t, dir, test.args...)
+			if code != 0 {
+				t.Fatalf("%v: exit code %d\n%s", test.args, code, out)
+			}
+			test.check(t, dir, out)
+		})
+	}
+}
+
+func TestC4groupCreate(t *testing.T) {
+	dir := t.TempDir()
+	writeTestDir(t, dir, map[string]string{"New.txt": "new"})
+	if code, out := runC4group(t, dir, "-v", "Created.ocd", "-a", "New.txt"); code != 0 {
+		t.Fatalf("exit code %d\n%s", code, out)
+	}
+	g := openTestGroup(t, filepath.Join(dir, "Created.ocd"))
+	if names := entryNames(g); !reflect.DeepEqual(names, []string{"New.txt"}) {
+		t.Errorf("got %v", names)
+	}
+}
+
+// TestC4groupListRoundTrip lists groups made with Writer, like the engine
+// tests in the root package, but with this command.
+func TestC4groupListRoundTrip(t *testing.T) {
+	str := "Hello World!"
+	tests := []struct {
+		name  string
+		write func(cw *c4group.Writer) error
+		want  []string
+	}{
+		{"empty", func(cw *c4group.Writer) error {
+			return cw.WriteHeader(&c4group.Header{Entries: 0})
+		}, []string{"0 Entries"}},
+		{"empty entry", func(cw *c4group.Writer) error {
+			if err := cw.WriteHeader(&c4group.Header{Entries: 1}); err != nil {
+				return err
+			}
+			return cw.WriteEntry(&c4group.Entry{Filename: "foobar.txt"})
+		}, []string{"foobar.txt", "1 Entries"}},
+		{"sub group", func(cw *c4group.Writer) error {
+			if err := cw.WriteHeader(&c4group.Header{Entries: 2}); err != nil {
+				return err
+			}
+			if err := cw.WriteEntry(&c4group.Entry{Filename: "foobar.txt", Size: len(str)}); err != nil {
+				return err
+			}
+			err := cw.WriteEntry(&c4group.Entry{
+				Filename: "SubGroup.ocg",
+				IsGroup:  true,
+				Size:     c4group.HeaderSize + c4group.EntrySize + len(str),
+			})
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(cw, str); err != nil {
+				return err
+			}
+			sub, err := cw.CreateSubGroup(&c4group.Header{Entries: 1})
+			if err != nil {
+				return err
+			}
+			if err := sub.WriteEntry(&c4group.Entry{Filename: "barbaz.txt", Size: len(str), Executable: true}); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(sub, str); err != nil {
+				return err
+			}
+			return sub.Close()
+		}, []string{"foobar.txt", "SubGroup.ocg", "(Group)", "2 Entries"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := os.Create(filepath.Join(dir, "Test.ocd"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			cw := c4group.NewWriter(f)
+			if err := test.write(cw); err != nil {
+				t.Fatal(err)
+			}
+			if err := cw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+			code, out := runC4group(t, dir, "Test.ocd", "-l")
+			if code != 0 {
+				t.Fatalf("exit code %d\n%s", code, out)
+			}
+			for _, s := range test.want {
+				if !strings.Contains(out, s) {
+					t.Errorf("missing %q in output:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
+
+func TestC4groupViewMalformedName(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "Test.ocd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cw := c4group.NewWriter(f)
+	if err := cw.WriteHeader(&c4group.Header{Entries: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.WriteEntry(&c4group.Entry{Filename: "a/b.txt", Size: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(cw, "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if code, out := runC4group(t, dir, "Test.ocd", "-v", "*"); code != 1 {
+		t.Errorf("got exit code %d, want 1\n%s", code, out)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/lluchs/c4group-go"
+	"github.com/lluchs/c4group-go/core"
+)
+
+// convertAction converts Clonk Rage groups and directories to OpenClonk
+// names.
+func convertAction(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	dryRun := flags.Bool("n", false, "only print what would be converted")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "convert [-n] <group or directory>...")
+		fmt.Fprintln(flags.Output(), "Renames .c4? files and directories recursively to .oc? and converts")
+		fmt.Fprintln(flags.Output(), "definition references in Scenario.txt.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	c := &converter{dryRun: *dryRun}
+	for _, p := range flags.Args() {
+		if err := c.convert(p); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+type converter struct {
+	dryRun bool
+}
+
+// convert converts a file or directory tree.
+func (c *converter) convert(root string) error {
+	var dirs []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			dirs = append(dirs, p)
+			return nil
+		}
+		return c.convertFile(p)
+	})
+	if err != nil {
+		return err
+	}
+	// Rename directories bottom-up so that the paths stay valid.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := c.rename(dirs[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *converter) convertFile(p string) error {
+	name := filepath.Base(p)
+	if name == c4group.C4CFN_ScenarioCore {
+		return c.convertScenario(p)
+	}
+	newName, ok := c4group.ConvertLegacyName(name)
+	if !ok {
+		return nil
+	}
+	if !isPackedGroup(p) {
+		return c.rename(p)
+	}
+	newPath := filepath.Join(filepath.Dir(p), newName)
+	if err := checkRenameTarget(p, newPath); err != nil {
+		return err
+	}
+	fmt.Printf("%s -> %s\n", p, newPath)
+	if c.dryRun {
+		return nil
+	}
+	if err := rewriteGroup(p, func(w io.Writer, group *c4group.Group) error {
+		return c4group.ConvertLegacy(w, newName, group)
+	}); err != nil {
+		return fmt.Errorf("%s: %w", p, err)
+	}
+	return os.Rename(p, newPath)
+}
+
+// rename renames a file or directory with a legacy extension.
+func (c *converter) rename(p string) error {
+	newName, ok := c4group.ConvertLegacyName(filepath.Base(p))
+	if !ok {
+		return nil
+	}
+	newPath := filepath.Join(filepath.Dir(p), newName)
+	if err := checkRenameTarget(p, newPath); err != nil {
+		return err
+	}
+	fmt.Printf("%s -> %s\n", p, newPath)
+	if c.dryRun {
+		return nil
+	}
+	return os.Rename(p, newPath)
+}
+
+// checkRenameTarget fails if renaming p to newPath would replace a file, like
+// ConvertLegacy does for entries.
+func checkRenameTarget(p, newPath string) error {
+	if _, err := os.Lstat(newPath); err == nil {
+		return fmt.Errorf("%w: %s and %s", c4group.ErrEntryExists, p, newPath)
+	}
+	return nil
+}
+
+// convertScenario converts the definition references of a Scenario.txt file.
+func (c *converter) convertScenario(p string) error {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	f, err := core.Parse(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	if !c4group.ConvertLegacyScenario(f) {
+		return nil
+	}
+	fmt.Printf("%s: converted definitions\n", p)
+	if c.dryRun {
+		return nil
+	}
+	return replaceFile(p, func(w io.Writer, file *os.File) error {
+		_, err := f.WriteTo(w)
+		return err
+	})
+}
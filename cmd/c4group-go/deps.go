@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/lluchs/c4group-go/defs"
+)
+
+// depsAction prints the dependency graph of the definitions and scenarios in
+// a group.
+func depsAction(args []string) int {
+	flags := flag.NewFlagSet("deps", flag.ContinueOnError)
+	format := flags.String("format", "dot", "output format: dot or json")
+	needs := flags.String("needs", "", "only print the IDs of all definitions needed by the given scenario path")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "deps [-format=dot|json] [-needs <scenario>] <group>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || (*format != "dot" && *format != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	fsys, closer, err := openGroupFS(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closer.Close()
+	cat, err := defs.Index(fsys, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, err := range cat.Errors {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+	graph, err := defs.Dependencies(fsys, cat)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, id := range graph.Missing {
+		fmt.Fprintln(os.Stderr, "warning: missing definition", id)
+	}
+
+	if *needs != "" {
+		for _, id := range graph.Needs(defs.Node{Kind: defs.ScenarioNode, Name: *needs}) {
+			fmt.Println(id)
+		}
+		return 0
+	}
+	switch *format {
+	case "dot":
+		printDot(os.Stdout, graph)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(graph); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// printDot writes the graph in Graphviz format.
+func printDot(w io.Writer, g *defs.Graph) {
+	shapes := map[defs.NodeKind]string{
+		defs.DefNode:      "box",
+		defs.ScenarioNode: "ellipse",
+		defs.PackNode:     "folder",
+	}
+	fmt.Fprintln(w, "digraph deps {")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(w, "\t%s [shape=%s];\n", strconv.Quote(n.Name), shapes[n.Kind])
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(w, "\t%s -> %s [label=%s];\n", strconv.Quote(e.From.Name), strconv.Quote(e.To.Name), e.Kind)
+	}
+	fmt.Fprintln(w, "}")
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/lluchs/c4group-go"
+)
+
+// diffEntry describes a difference for JSON output.
+type diffEntry struct {
+	Kind     c4group.DiffKind `json:"kind"`
+	Path     string           `json:"path"`
+	OldSize  *int             `json:"old_size,omitempty"`
+	NewSize  *int             `json:"new_size,omitempty"`
+	Field    string           `json:"field,omitempty"`
+	OldValue string           `json:"old_value,omitempty"`
+	NewValue string           `json:"new_value,omitempty"`
+}
+
+// diffAction compares two packed groups. Like diff(1), it exits with 1 if
+// the groups differ.
+func diffAction(args []string) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format: text or json")
+	text := flags.Bool("text", false, "show unified diffs of modified .txt and .c files")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "diff [-format=text|json] [-text] <old group> <new group>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 || (*format != "text" && *format != "json") {
+		flags.Usage()
+		return 2
+	}
+
+	var readers [2]*c4group.Reader
+	for i, name := range flags.Args() {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		defer file.Close()
+		if readers[i], err = c4group.NewReader(file); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			return 2
+		}
+		defer readers[i].Close()
+	}
+	differ := &c4group.Differ{}
+	if *text {
+		differ.KeepData = func(p string, e *c4group.Entry) bool {
+			ext := strings.ToLower(path.Ext(p))
+			return ext == ".txt" || ext == ".c"
+		}
+	}
+	diffs, err := differ.Diff(readers[0], readers[1])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	switch *format {
+	case "text":
+		printDiffText(os.Stdout, diffs, flags.Arg(0), flags.Arg(1))
+	case "json":
+		entries := []diffEntry{}
+		for _, d := range diffs {
+			e := diffEntry{Kind: d.Kind, Path: d.Path, Field: d.Field, OldValue: d.OldValue, NewValue: d.NewValue}
+			if d.Old != nil {
+				e.OldSize = &d.Old.Size
+			}
+			if d.New != nil {
+				e.NewSize = &d.New.Size
+			}
+			entries = append(entries, e)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(entries); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	if len(diffs) > 0 {
+		return 1
+	}
+	return 0
+}
+
+func printDiffText(w io.Writer, diffs []c4group.Difference, oldName, newName string) {
+	for _, d := range diffs {
+		switch d.Kind {
+		case c4group.HeaderChanged:
+			group := d.Path
+			if group == "" {
+				group = "group"
+			}
+			fmt.Fprintf(w, "header  %s: %s %q -> %q\n", group, d.Field, d.OldValue, d.NewValue)
+		case c4group.Added:
+			fmt.Fprintf(w, "added   %s (%d bytes)\n", d.Path, d.New.Size)
+		case c4group.Removed:
+			fmt.Fprintf(w, "removed %s (%d bytes)\n", d.Path, d.Old.Size)
+		case c4group.Modified:
+			fmt.Fprintf(w, "changed %s (%d -> %d bytes)\n", d.Path, d.Old.Size, d.New.Size)
+			if d.OldData != nil || d.NewData != nil {
+				writeUnifiedDiff(w, path.Join(oldName, d.Path), path.Join(newName, d.Path), d.OldData, d.NewData)
+			}
+		case c4group.Reordered:
+			fmt.Fprintf(w, "moved   %s\n", d.Path)
+		}
+	}
+}
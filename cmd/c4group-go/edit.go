@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/lluchs/c4group-go"
+)
+
+// editAction modifies a group in place.
+func editAction(action string, args []string) int {
+	usage := map[string]string{
+		"add":     "add <group> <file> [<entry>]",
+		"delete":  "delete <group> <entry>...",
+		"rename":  "rename <group> <entry> <new name>",
+		"replace": "replace <group> <entry> <file>",
+	}
+	var edit func(ed *c4group.Editor) error
+	switch {
+	case action == "add" && (len(args) == 2 || len(args) == 3):
+		entry := filepath.Base(args[1])
+		if len(args) == 3 {
+			entry = args[2]
+		}
+		edit = func(ed *c4group.Editor) error {
+			return ed.AddPath(entry, args[1])
+		}
+	case action == "delete" && len(args) >= 2:
+		edit = func(ed *c4group.Editor) error {
+			for _, entry := range args[1:] {
+				if err := ed.Delete(entry); err != nil {
+					return fmt.Errorf("%s: %w", entry, err)
+				}
+			}
+			return nil
+		}
+	case action == "rename" && len(args) == 3:
+		edit = func(ed *c4group.Editor) error {
+			return ed.Rename(args[1], args[2])
+		}
+	case action == "replace" && len(args) == 3:
+		edit = func(ed *c4group.Editor) error {
+			return ed.ReplacePath(args[1], args[2])
+		}
+	default:
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], usage[action])
+		return 2
+	}
+	if err := editGroup(args[0], edit); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// editGroup applies changes to a packed group file.
+func editGroup(filename string, edit func(ed *c4group.Editor) error) error {
+	return rewriteGroup(filename, func(w io.Writer, group *c4group.Group) error {
+		editor := c4group.NewEditor(filepath.Base(filename), group)
+		if err := edit(editor); err != nil {
+			return err
+		}
+		return editor.Pack(w)
+	})
+}
+
+// rewriteGroup replaces a packed group file.
+func rewriteGroup(filename string, write func(w io.Writer, group *c4group.Group) error) error {
+	return replaceFile(filename, func(w io.Writer, file *os.File) error {
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		group, err := c4group.OpenReaderAt(file, info.Size())
+		if err != nil {
+			return err
+		}
+		return write(w, group)
+	})
+}
+
+// replaceFile replaces a file with the output of write, which receives the
+// original file for reading. The output is written to a temporary file
+// which then replaces the original.
+func replaceFile(filename string, write func(w io.Writer, file *os.File) error) (err error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+	w := bufio.NewWriter(tmp)
+	if err = write(w, file); err != nil {
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	if err = tmp.Chmod(info.Mode()); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/lluchs/c4group-go"
+)
+
+// extractAction unpacks a group to a directory.
+func extractAction(args []string) int {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	overwrite := flags.Bool("overwrite", false, "replace existing files")
+	packed := flags.Bool("packed", false, "extract child groups as packed files instead of directories")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "extract [-overwrite] [-packed] <group> <directory>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer file.Close()
+	reader, err := c4group.NewReader(file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer reader.Close()
+
+	err = c4group.Extract(reader, flags.Arg(1), &c4group.ExtractOptions{
+		PackChildGroups: *packed,
+		Overwrite:       *overwrite,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
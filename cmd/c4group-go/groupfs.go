@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+
+	"github.com/lluchs/c4group-go"
+)
+
+// groupFS is a file system over a group opened with c4group.Open.
+type groupFS struct {
+	*c4group.FS
+	a c4group.Archive
+}
+
+// Close closes the child groups and the group itself.
+func (g *groupFS) Close() error {
+	err := g.FS.Close()
+	if cerr := g.a.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// openGroupFS opens a packed group file or an unpacked group directory.
+// Packed groups inside directories appear as directories as well. The
+// returned Closer must be closed after using the file system.
+func openGroupFS(filename string) (fs.FS, io.Closer, error) {
+	a, err := c4group.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	fsys := &groupFS{FS: c4group.NewFS(a), a: a}
+	return fsys, fsys, nil
+}
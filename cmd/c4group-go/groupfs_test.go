@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lluchs/c4group-go"
+	"github.com/lluchs/c4group-go/core"
+)
+
+// writeTestDir creates files below dir, including parent directories.
+func writeTestDir(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// packTestDir replaces the directory dir with a packed group.
+func packTestDir(t *testing.T, dir string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := c4group.PackDir(&buf, dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGroupFSPackedChild(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Test.ocs")
+	writeTestDir(t, dir, map[string]string{
+		"Title.txt":                        "DE:Titel\nUS:Title\n",
+		"Objects.ocd/Rock.ocd/DefCore.txt": "[DefCore]\nid=Rock\n",
+		"Objects.ocd/Rock.ocd/TitleDE.txt": "Stein",
+	})
+	packTestDir(t, filepath.Join(dir, "Objects.ocd"))
+
+	fsys, closer, err := openGroupFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closer.Close()
+	if fi, err := fs.Stat(fsys, "Objects.ocd"); err != nil || !fi.IsDir() {
+		t.Fatalf("Objects.ocd: got %v, %v", fi, err)
+	}
+	b, err := fs.ReadFile(fsys, "Objects.ocd/Rock.ocd/TitleDE.txt")
+	if err != nil || string(b) != "Stein" {
+		t.Errorf("TitleDE.txt: got %q, %v", b, err)
+	}
+
+	// i18n-check looks into the packed definition.
+	problems, err := core.CheckTranslations(fsys, &core.TranslationOptions{Reference: "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Objects.ocd/Rock.ocd/TitleUS.txt: missing file (US)"
+	if len(problems) != 1 || problems[0].String() != want {
+		t.Errorf("got %v, want %q", problems, want)
+	}
+}
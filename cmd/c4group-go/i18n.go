@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lluchs/c4group-go/core"
+)
+
+// i18nCheckAction reports incomplete translations. It fails if there are any
+// problems.
+func i18nCheckAction(args []string) int {
+	flags := flag.NewFlagSet("i18n-check", flag.ContinueOnError)
+	langs := flags.String("lang", "", "comma-separated list of required languages (default: all languages found)")
+	ref := flags.String("ref", "US", "reference language for string table keys")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "i18n-check [-lang DE,US] [-ref US] <group>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	fsys, closer, err := openGroupFS(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closer.Close()
+	opts := &core.TranslationOptions{Reference: *ref}
+	if *langs != "" {
+		opts.Languages = strings.Split(*langs, ",")
+	}
+	problems, err := core.CheckTranslations(fsys, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d translation problems\n", len(problems))
+		return 1
+	}
+	return 0
+}
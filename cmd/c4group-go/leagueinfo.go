@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lluchs/c4group-go"
+	"github.com/lluchs/c4group-go/core"
+)
+
+// leagueInfo contains the information needed to upload a scenario to the
+// league.
+type leagueInfo struct {
+	Filename    string            `json:"filename"`
+	Author      string            `json:"author"`
+	Created     int64             `json:"created,omitempty"` // Unix time
+	CRC         uint32            `json:"crc"`
+	SHA1        string            `json:"sha1"`
+	ContentsCRC uint32            `json:"contentsCRC"`
+	Titles      map[string]string `json:"titles"` // by language code, e.g. "DE"
+	Icon        *int              `json:"icon,omitempty"`
+	MaxPlayers  *int              `json:"maxPlayers,omitempty"`
+}
+
+var leagueInfoRenderers = map[string]func(w io.Writer, info *leagueInfo) error{
+	"text": renderLeagueInfoText,
+	"js":   renderLeagueInfoJS,
+	"json": renderLeagueInfoJSON,
+	"yaml": renderLeagueInfoYAML,
+}
+
+// leagueInfoAction prints league information about a scenario.
+func leagueInfoAction(args []string) int {
+	flags := flag.NewFlagSet("league-info", flag.ContinueOnError)
+	format := flags.String("format", "text", "output format: text, js, json or yaml")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "league-info [-format=text|js|json|yaml] <group>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	render, ok := leagueInfoRenderers[*format]
+	if flags.NArg() != 1 || !ok {
+		flags.Usage()
+		return 2
+	}
+	info, err := readLeagueInfo(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := render(os.Stdout, info); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// readLeagueInfo collects league information from the scenario file. The
+// hashes are computed from the compressed file, everything else from one
+// random-access group.
+func readLeagueInfo(filename string) (*leagueInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	g, err := c4group.OpenReaderAt(file, stat.Size())
+	if err != nil {
+		return nil, err
+	}
+
+	info := &leagueInfo{
+		Filename: path.Base(filename),
+		Author:   g.Header.Author,
+		Titles:   make(map[string]string),
+	}
+	if g.Header.Ctime.Unix() > 0 {
+		info.Created = g.Header.Ctime.Unix()
+	}
+	if info.CRC, info.SHA1, err = CalculateHashes(file); err != nil {
+		return nil, err
+	}
+	if info.ContentsCRC, err = c4group.GroupContentsCRC(g); err != nil {
+		return nil, fmt.Errorf("error calculating contents CRC: %s", err)
+	}
+
+	// For league info, look for Title.txt and Scenario.txt
+	for _, entry := range g.Entries {
+		switch entry.Filename {
+		case "Scenario.txt":
+			r, err := g.Open(entry.Filename)
+			if err != nil {
+				return nil, err
+			}
+			// Only decode what we need so that unrelated invalid values
+			// don't matter.
+			var scenario struct {
+				Head struct{ Icon, MaxPlayer int }
+			}
+			// Distinguish missing values from zero.
+			scenario.Head.Icon = -1
+			scenario.Head.MaxPlayer = -1
+			if _, err := core.Decode(r, &scenario); err != nil {
+				return nil, fmt.Errorf("error reading Scenario.txt: %s", err)
+			}
+			if scenario.Head.Icon != -1 {
+				info.Icon = &scenario.Head.Icon
+			}
+			if scenario.Head.MaxPlayer != -1 {
+				info.MaxPlayers = &scenario.Head.MaxPlayer
+			}
+		case "Title.txt":
+			r, err := g.Open(entry.Filename)
+			if err != nil {
+				return nil, err
+			}
+			title, err := readToString(r)
+			if err != nil {
+				return nil, fmt.Errorf("error reading Title.txt: %s", err)
+			}
+			info.Titles = core.LanguageStrings(title)
+		}
+	}
+	return info, nil
+}
+
+// languages returns the title languages in alphabetical order.
+func (info *leagueInfo) languages() []string {
+	var langs []string
+	for lang := range info.Titles {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// renderLeagueInfoText prints a human-readable summary followed by the
+// JavaScript snippet.
+func renderLeagueInfoText(w io.Writer, info *leagueInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	if info.Author != "" {
+		fmt.Fprintf(tw, "Author:\t%s\n", info.Author)
+	}
+	if info.Created != 0 {
+		fmt.Fprintf(tw, "Time:\t%s\n", time.Unix(info.Created, 0))
+	}
+	fmt.Fprintf(tw, "CRC32:\t%d\n", info.CRC)
+	fmt.Fprintf(tw, "SHA-1:\t%s\n", info.SHA1)
+	fmt.Fprintf(tw, "Contents CRC:\t%d\n", info.ContentsCRC)
+	for _, lang := range info.languages() {
+		fmt.Fprintf(tw, "Title (%s):\t%s\n", lang, info.Titles[lang])
+	}
+	if info.Icon != nil {
+		fmt.Fprintf(tw, "Icon:\t%d\n", *info.Icon)
+	}
+	if info.MaxPlayers != nil {
+		fmt.Fprintf(tw, "Max. players:\t%d\n", *info.MaxPlayers)
+	}
+	fmt.Fprintln(tw)
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	return renderLeagueInfoJS(w, info)
+}
+
+// renderLeagueInfoJS prints JavaScript for pasting into the browser console
+// on the league's scenario upload page.
+func renderLeagueInfoJS(w io.Writer, info *leagueInfo) error {
+	addJS := func(key, value string) {
+		fmt.Fprintf(w, "document.all[%s].value=%s;", jsString(key), jsString(value))
+	}
+	addJS("scenario[name][1]", info.Titles["US"])
+	addJS("scenario[name][2]", info.Titles["DE"])
+	if info.Icon != nil {
+		fmt.Fprintf(w, "document.all['scenario[icon_number]'][%d].checked=true;", *info.Icon)
+	}
+	addJS("versions[0][hash]", strconv.FormatUint(uint64(info.CRC), 10))
+	addJS("versions[0][hash_sha]", info.SHA1)
+	addJS("versions[0][filename]", info.Filename)
+	addJS("versions[0][author]", info.Author)
+	addJS("versions[0][comment]", "manual import")
+	if info.MaxPlayers != nil {
+		fmt.Fprintf(w, `document.querySelectorAll('[name$="[max_player_count]"]').forEach(e=>e.value=%d);`, *info.MaxPlayers)
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// jsString quotes s as JavaScript string literal. JSON strings are valid
+// JavaScript, and encoding/json also escapes U+2028 and U+2029.
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func renderLeagueInfoJSON(w io.Writer, info *leagueInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(info)
+}
+
+// renderLeagueInfoYAML prints the same fields as the JSON output. Strings
+// are double-quoted, which YAML parses like JSON strings. This also keeps
+// language codes like NO from being read as booleans.
+func renderLeagueInfoYAML(w io.Writer, info *leagueInfo) error {
+	fmt.Fprintf(w, "filename: %s\n", jsString(info.Filename))
+	fmt.Fprintf(w, "author: %s\n", jsString(info.Author))
+	if info.Created != 0 {
+		fmt.Fprintf(w, "created: %d\n", info.Created)
+	}
+	fmt.Fprintf(w, "crc: %d\n", info.CRC)
+	fmt.Fprintf(w, "sha1: %s\n", jsString(info.SHA1))
+	fmt.Fprintf(w, "contentsCRC: %d\n", info.ContentsCRC)
+	if len(info.Titles) == 0 {
+		fmt.Fprintln(w, "titles: {}")
+	} else {
+		fmt.Fprintln(w, "titles:")
+		for _, lang := range info.languages() {
+			fmt.Fprintf(w, "  %s: %s\n", jsString(lang), jsString(info.Titles[lang]))
+		}
+	}
+	if info.Icon != nil {
+		fmt.Fprintf(w, "icon: %d\n", *info.Icon)
+	}
+	var err error
+	if info.MaxPlayers != nil {
+		_, err = fmt.Fprintf(w, "maxPlayers: %d\n", *info.MaxPlayers)
+	}
+	return err
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testLeagueInfo has titles which need quoting in JavaScript and YAML.
+func testLeagueInfo() *leagueInfo {
+	icon, maxPlayers := 3, 4
+	return &leagueInfo{
+		Filename:    `Say "Hi": Part 2.ocs`,
+		Author:      "O'Neil",
+		CRC:         1,
+		SHA1:        "abc",
+		ContentsCRC: 2,
+		Titles: map[string]string{
+			"DE": `Sag "Hallo": Teil 2`,
+			"NO": "yes",
+			"US": "Line 1\nLine 2: </script>",
+		},
+		Icon:       &icon,
+		MaxPlayers: &maxPlayers,
+	}
+}
+
+func TestRenderLeagueInfoJS(t *testing.T) {
+	var buf bytes.Buffer
+	if err := renderLeagueInfoJS(&buf, testLeagueInfo()); err != nil {
+		t.Fatal(err)
+	}
+	// encoding/json escapes < and >, which JavaScript and YAML both accept.
+	want := `document.all["scenario[name][1]"].value="Line 1\nLine 2: \u003c/script\u003e";` +
+		`document.all["scenario[name][2]"].value="Sag \"Hallo\": Teil 2";` +
+		`document.all['scenario[icon_number]'][3].checked=true;` +
+		`document.all["versions[0][hash]"].value="1";` +
+		`document.all["versions[0][hash_sha]"].value="abc";` +
+		`document.all["versions[0][filename]"].value="Say \"Hi\": Part 2.ocs";` +
+		`document.all["versions[0][author]"].value="O'Neil";` +
+		`document.all["versions[0][comment]"].value="manual import";` +
+		`document.querySelectorAll('[name$="[max_player_count]"]').forEach(e=>e.value=4);` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRenderLeagueInfoYAML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := renderLeagueInfoYAML(&buf, testLeagueInfo()); err != nil {
+		t.Fatal(err)
+	}
+	want := `filename: "Say \"Hi\": Part 2.ocs"
+author: "O'Neil"
+crc: 1
+sha1: "abc"
+contentsCRC: 2
+titles:
+  "DE": "Sag \"Hallo\": Teil 2"
+  "NO": "yes"
+  "US": "Line 1\nLine 2: \u003c/script\u003e"
+icon: 3
+maxPlayers: 4
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := renderLeagueInfoYAML(&buf, &leagueInfo{Filename: "Empty.ocs"}); err != nil {
+		t.Fatal(err)
+	}
+	want = `filename: "Empty.ocs"
+author: ""
+crc: 0
+sha1: ""
+contentsCRC: 0
+titles: {}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReadLeagueInfo(t *testing.T) {
+	scenario := filepath.Join(t.TempDir(), "Test.ocs")
+	writeTestDir(t, scenario, map[string]string{
+		"Title.txt":    "DE:Sag \"Hallo\": Teil 2\nUS:Say \"Hi\": Part 2\n",
+		"Scenario.txt": "[Head]\nIcon=3\nMaxPlayer=4\n",
+	})
+	packTestDir(t, scenario)
+
+	info, err := readLeagueInfo(scenario)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantTitles := map[string]string{"DE": `Sag "Hallo": Teil 2`, "US": `Say "Hi": Part 2`}
+	if !reflect.DeepEqual(info.Titles, wantTitles) {
+		t.Errorf("got titles %q, want %q", info.Titles, wantTitles)
+	}
+	if info.Filename != "Test.ocs" || info.Icon == nil || *info.Icon != 3 || info.MaxPlayers == nil || *info.MaxPlayers != 4 {
+		t.Errorf("got %+v", info)
+	}
+	if info.SHA1 == "" || info.CRC == 0 || info.ContentsCRC == 0 {
+		t.Errorf("missing hashes: %+v", info)
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/lluchs/c4group-go"
+)
+
+// listEntry describes an entry for list output.
+type listEntry struct {
+	Path       string  `json:"path"` // slash-separated path in the group
+	Size       int     `json:"size"`
+	Group      bool    `json:"group"`
+	Executable bool    `json:"executable"`
+	Mtime      int64   `json:"mtime,omitempty"` // Unix time
+	CRC        *uint32 `json:"crc,omitempty"`
+}
+
+// listAction prints the contents of a group.
+func listAction(args []string) int {
+	flags := flag.NewFlagSet("list", flag.ContinueOnError)
+	recursive := flags.Bool("R", false, "list child groups recursively")
+	format := flags.String("format", "text", "output format: text, json or tsv")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "list [-R] [-format=text|json|tsv] <group> [<wildcards>]")
+		fmt.Fprintln(flags.Output(), "Wildcards may contain several patterns separated by '|'.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 || (*format != "text" && *format != "json" && *format != "tsv") {
+		flags.Usage()
+		return 2
+	}
+	filter := "*"
+	if flags.NArg() == 2 {
+		filter = flags.Arg(1)
+	}
+
+	listing, err := openListing(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer listing.Close()
+	var entries []listEntry
+	err = listing.walk(*recursive, func(e listEntry) error {
+		if c4group.WildcardListMatch(filter, path.Base(e.Path)) {
+			entries = append(entries, e)
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	switch *format {
+	case "text":
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		if hdr := listing.Header; hdr != nil {
+			if hdr.Author != "" {
+				fmt.Fprintf(w, "Author:\t%s\n", hdr.Author)
+			}
+			if hdr.Ctime.Unix() != 0 {
+				fmt.Fprintf(w, "Time:\t%s\n", hdr.Ctime)
+			}
+		}
+		fmt.Fprintln(w)
+		w.Flush()
+		printListText(os.Stdout, entries)
+	case "json":
+		if entries == nil {
+			entries = []listEntry{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(entries)
+	case "tsv":
+		err = printListTSV(os.Stdout, entries)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// groupListing walks the entries of a packed group file, which may be a
+// pipe, or of a directory.
+type groupListing struct {
+	Header *c4group.Header // nil for directories
+
+	dir    string
+	file   *os.File
+	reader *c4group.Reader
+}
+
+// openListing opens the packed group file or directory filename.
+func openListing(filename string) (*groupListing, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if info.IsDir() {
+		file.Close()
+		return &groupListing{dir: filename}, nil
+	}
+	reader, err := c4group.NewReader(bufio.NewReader(file))
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &groupListing{Header: &reader.Header, file: file, reader: reader}, nil
+}
+
+// walk calls fn for each entry in group order. With recursive, child groups
+// are descended into after their entry. walk can only be called once.
+func (l *groupListing) walk(recursive bool, fn func(listEntry) error) error {
+	if l.reader == nil {
+		return walkDir(l.dir, "", recursive, fn)
+	}
+	return walkPacked(l.reader, "", recursive, fn)
+}
+
+// Close closes the group. Pipes are consumed completely so that the writer
+// doesn't fail.
+func (l *groupListing) Close() error {
+	if l.file == nil {
+		return nil
+	}
+	l.reader.Close()
+	if info, err := l.file.Stat(); err == nil && !info.Mode().IsRegular() {
+		io.Copy(ioutil.Discard, l.file)
+	}
+	return l.file.Close()
+}
+
+func walkPacked(reader *c4group.Reader, prefix string, recursive bool, fn func(listEntry) error) error {
+	for {
+		entry, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		e := listEntry{
+			Path:       prefix + entry.Filename,
+			Size:       entry.Size,
+			Group:      entry.IsGroup,
+			Executable: entry.Executable,
+		}
+		if entry.Mtime.Unix() > 0 {
+			e.Mtime = entry.Mtime.Unix()
+		}
+		if entry.HasCRC {
+			crc := entry.CRC
+			e.CRC = &crc
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+		if recursive && entry.IsGroup {
+			sub, err := reader.ReadGroup()
+			if err != nil {
+				return err
+			}
+			if err := walkPacked(sub, e.Path+"/", recursive, fn); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func walkDir(dir, prefix string, recursive bool, fn func(listEntry) error) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, de := range entries {
+		info, err := de.Info()
+		if err != nil {
+			return err
+		}
+		e := listEntry{
+			Path:       prefix + de.Name(),
+			Size:       int(info.Size()),
+			Group:      de.IsDir(),
+			Executable: !de.IsDir() && info.Mode()&0111 != 0,
+			Mtime:      info.ModTime().Unix(),
+		}
+		if err := fn(e); err != nil {
+			return err
+		}
+		if recursive && de.IsDir() {
+			if err := walkDir(filepath.Join(dir, de.Name()), e.Path+"/", recursive, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printListText prints path, size and attributes similar to c4group -l.
+func printListText(w io.Writer, entries []listEntry) {
+	tw := newListWriter(w)
+	for _, entry := range entries {
+		printListEntry(tw, entry)
+	}
+	fmt.Fprintln(tw)
+	tw.Flush()
+}
+
+// newListWriter aligns the columns written by printListEntry.
+func newListWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 5, 0, 3, ' ', tabwriter.AlignRight)
+}
+
+// printListEntry prints a single line of c4group -l output.
+func printListEntry(w io.Writer, e listEntry) {
+	info := ""
+	if e.Group {
+		info += " (Group)"
+	}
+	if e.Executable {
+		info += " (Executable)"
+	}
+	fmt.Fprintf(w, "%s\t%d Bytes\t%s\n", e.Path, e.Size, info)
+}
+
+// printListTSV prints one line per entry with a header line.
+func printListTSV(w io.Writer, entries []listEntry) error {
+	if _, err := fmt.Fprintln(w, "path\tsize\tgroup\texecutable\tmtime\tcrc"); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		crc := ""
+		if e.CRC != nil {
+			crc = strconv.FormatUint(uint64(*e.CRC), 10)
+		}
+		_, err := fmt.Fprintf(w, "%s\t%d\t%t\t%t\t%d\t%s\n", e.Path, e.Size, e.Group, e.Executable, e.Mtime, crc)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
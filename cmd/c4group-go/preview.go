@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+
+	"github.com/lluchs/c4group-go/preview"
+)
+
+// previewAction renders the map of a scenario to a PNG file.
+func previewAction(args []string) int {
+	flags := flag.NewFlagSet("preview", flag.ContinueOnError)
+	materials := flags.String("materials", "", "system Material.ocg for materials the scenario doesn't define")
+	width := flags.Int("width", 0, "maximum width of the image (default: map size)")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "preview [-materials <Material.ocg>] [-width <pixels>] <scenario> <output.png>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	scenario, closer, err := openGroupFS(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer closer.Close()
+	opts := &preview.Options{Width: *width}
+	if *materials != "" {
+		fsys, closer, err := openGroupFS(*materials)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer closer.Close()
+		opts.Materials = fsys
+	}
+	img, err := preview.Render(scenario, opts)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	if err := writePNG(flags.Arg(1), img); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// writePNG encodes img to the file name.
+func writePNG(name string, img image.Image) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := png.Encode(w, img); err != nil {
+		file.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lluchs/c4group-go"
+)
+
+// sortAction sorts packed groups in place into the engine's load order, or
+// only lists misplaced entries with -check.
+func sortAction(args []string) int {
+	flags := flag.NewFlagSet("sort", flag.ContinueOnError)
+	checkOnly := flags.Bool("check", false, "only list entries which are out of order")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "sort [-check] <group>...")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+	status := 0
+	for _, filename := range flags.Args() {
+		var err error
+		if *checkOnly {
+			var misplaced []string
+			misplaced, err = checkGroupOrder(filename)
+			for _, p := range misplaced {
+				fmt.Printf("%s: %s is out of order\n", filename, p)
+				status = 1
+			}
+		} else {
+			err = editGroup(filename, func(ed *c4group.Editor) error {
+				return ed.SortAll()
+			})
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			return 2
+		}
+	}
+	return status
+}
+
+func checkGroupOrder(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	r, err := c4group.NewReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return c4group.CheckOrder(filepath.Base(filename), r)
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/lluchs/c4group-go/preview"
+)
+
+// thumbnailsAction writes the display images of all groups in a directory as
+// PNG files.
+func thumbnailsAction(args []string) int {
+	flags := flag.NewFlagSet("thumbnails", flag.ContinueOnError)
+	output := flags.String("o", "thumbnails", "output directory")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "thumbnails [-o <output dir>] <dir>")
+		fmt.Fprintln(flags.Output(), "Images are written to <output dir>/<group path>.<kind>.png.")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	root := flags.Arg(0)
+
+	failed := false
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && filepath.Clean(p) == filepath.Clean(*output) {
+			return fs.SkipDir
+		}
+		if !hasAssets(d.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if err := writeThumbnails(p, filepath.ToSlash(rel), *output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+		if d.IsDir() {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// hasAssets checks whether a group may have display images.
+func hasAssets(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".ocs", ".ocf", ".ocd", ".ocp":
+		return true
+	}
+	return false
+}
+
+// writeThumbnails extracts the images of the group at filename and all groups
+// inside it.
+func writeThumbnails(filename, rel, output string) error {
+	fsys, closer, err := openGroupFS(filename)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		name := path.Join(rel, p)
+		if !hasAssets(name) {
+			return nil
+		}
+		group, err := fs.Sub(fsys, p)
+		if err != nil {
+			return err
+		}
+		assets, err := preview.Assets(group, name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, asset := range assets {
+			out := filepath.Join(output, filepath.FromSlash(name)) + "." + string(asset.Kind) + ".png"
+			if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+				return err
+			}
+			if err := writePNG(out, asset.Image); err != nil {
+				return err
+			}
+			fmt.Println(out)
+		}
+		return nil
+	})
+}
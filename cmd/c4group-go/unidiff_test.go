@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// numberLines returns the lines "1" to "n", with replace applied to them.
+func numberLines(n int, replace map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		line, ok := replace[i]
+		if !ok {
+			line = strconv.Itoa(i) + "\n"
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func TestWriteUnifiedDiff(t *testing.T) {
+	// Expected hunks are the output of GNU diff -u.
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"identical", "a\nb\n", "a\nb\n", ""},
+		{"added to empty", "", "x\ny\n", "@@ -0,0 +1,2 @@\n+x\n+y\n"},
+		{"removed all", "x\ny\n", "", "@@ -1,2 +0,0 @@\n-x\n-y\n"},
+		{"middle", "a\nb\nc\nd\ne\nf\ng\nh\n", "a\nb\nc\nd\nE\nf\ng\nh\n",
+			"@@ -2,7 +2,7 @@\n b\n c\n d\n-e\n+E\n f\n g\n h\n"},
+		{"two hunks", numberLines(20, nil), numberLines(20, map[int]string{2: "two\n", 15: "", 20: "20\nadded\n"}),
+			"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -12,9 +12,9 @@\n 12\n 13\n 14\n-15\n 16\n 17\n 18\n 19\n 20\n+added\n"},
+		{"merged hunk", numberLines(20, nil), numberLines(20, map[int]string{2: "two\n", 9: "nine\n"}),
+			"@@ -1,12 +1,12 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+nine\n 10\n 11\n 12\n"},
+		{"split hunks", numberLines(20, nil), numberLines(20, map[int]string{2: "two\n", 10: "ten\n"}),
+			"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n" +
+				"@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+ten\n 11\n 12\n 13\n"},
+		{"CRLF", "a\r\nb\r\n", "a\nc\n", "@@ -1,2 +1,2 @@\n a\n-b\n+c\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		writeUnifiedDiff(&buf, "old", "new", []byte(test.old), []byte(test.new))
+		want := "--- old\n+++ new\n" + test.want
+		if buf.String() != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, buf.String(), want)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	// Every other line is common, but the changed part is too large for an
+	// LCS table.
+	n := 5000
+	a, b := make([]string, n), make([]string, n)
+	for i := range a {
+		a[i], b[i] = "common", "common"
+		if i%2 == 1 {
+			a[i], b[i] = "a"+strconv.Itoa(i), "b"+strconv.Itoa(i)
+		}
+	}
+	counts := make(map[byte]int)
+	var gotA, gotB []string
+	for _, op := range diffLines(a, b) {
+		counts[op.kind]++
+		if op.kind != '+' {
+			gotA = append(gotA, op.line)
+		}
+		if op.kind != '-' {
+			gotB = append(gotB, op.line)
+		}
+	}
+	if counts[' '] != 1 || counts['-'] != n-1 || counts['+'] != n-1 {
+		t.Errorf("expected a replacement after the common first line, got %v", counts)
+	}
+	if strings.Join(gotA, "\n") != strings.Join(a, "\n") || strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+		t.Error("diff doesn't reproduce the inputs")
+	}
+}
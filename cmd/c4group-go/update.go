@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/lluchs/c4group-go"
+)
+
+// makeUpdateAction writes an update group which turns one version of a group
+// into another.
+func makeUpdateAction(args []string) int {
+	flags := flag.NewFlagSet("make-update", flag.ContinueOnError)
+	name := flags.String("name", "", "name of the updated group (default: name of the new group)")
+	require := flags.String("require", "", "required engine version, e.g. 8,0")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage:", os.Args[0], "make-update [-name <name>] [-require <version>] <old group> <new group> <update.ocu>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 3 {
+		flags.Usage()
+		return 2
+	}
+	opts := &c4group.UpdateOptions{Name: *name}
+	if opts.Name == "" {
+		opts.Name = filepath.Base(flags.Arg(1))
+	}
+	if *require != "" {
+		for _, s := range strings.Split(*require, ",") {
+			v, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "invalid version:", *require)
+				return 2
+			}
+			opts.RequireVersion = append(opts.RequireVersion, v)
+		}
+	}
+
+	var groups [2]*c4group.Group
+	for i, filename := range flags.Args()[:2] {
+		group, file, err := openGroupFile(filename)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", filename, err)
+			return 1
+		}
+		defer file.Close()
+		groups[i] = group
+	}
+	if err := writeUpdate(flags.Arg(2), groups[0], groups[1], opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func writeUpdate(output string, old, new *c4group.Group, opts *c4group.UpdateOptions) (err error) {
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(output)
+		}
+	}()
+	w := bufio.NewWriter(file)
+	if err = c4group.MakeUpdate(w, old, new, opts); err != nil {
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// applyUpdateAction applies update groups to a packed group in place.
+func applyUpdateAction(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "Usage:", os.Args[0], "apply-update <group> <update.ocu>...")
+		return 2
+	}
+	filename := args[0]
+	for _, updateName := range args[1:] {
+		update, file, err := openGroupFile(updateName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", updateName, err)
+			return 1
+		}
+		err = rewriteGroup(filename, func(w io.Writer, group *c4group.Group) error {
+			return c4group.ApplyUpdate(w, filepath.Base(filename), group, update)
+		})
+		file.Close()
+		if errors.Is(err, c4group.ErrAlreadyUpdated) {
+			fmt.Fprintf(os.Stderr, "%s: already applied\n", updateName)
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", updateName, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+// openGroupFile opens a packed group file. The returned file must be closed
+// after using the group.
+func openGroupFile(filename string) (*c4group.Group, *os.File, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	group, err := c4group.OpenReaderAt(file, info.Size())
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return group, file, nil
+}
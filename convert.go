@@ -0,0 +1,131 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/lluchs/c4group-go/core"
+)
+
+// ConvertLegacy copies the Clonk Rage group src to w, renaming all entries
+// with legacy extensions with ConvertLegacyName and converting the
+// definition references in Scenario.txt. Entries are sorted with the
+// OpenClonk sort lists afterwards. name is the new filename of the group.
+func ConvertLegacy(w io.Writer, name string, src *Group) error {
+	b := builderFromGroup(name, src)
+	if _, err := b.convertLegacy(); err != nil {
+		return err
+	}
+	b.Sort()
+	return b.Pack(w)
+}
+
+// convertLegacy converts all entries recursively and reports whether any
+// entry changed.
+func (b *Builder) convertLegacy() (bool, error) {
+	// Renaming must not collide with existing entries, e.g. Foo.c4d with
+	// Foo.ocd.
+	names := make(map[string]string) // new name -> original name
+	for _, e := range b.entries {
+		newName, _ := ConvertLegacyName(e.Filename)
+		if other, ok := names[newName]; ok {
+			return false, fmt.Errorf("%w: %s and %s both become %s", ErrEntryExists, other, e.Filename, newName)
+		}
+		names[newName] = e.Filename
+	}
+	changed := false
+	for _, e := range b.entries {
+		if newName, ok := ConvertLegacyName(e.Filename); ok {
+			e.Filename = newName
+			e.HasCRC = false
+			changed = true
+		}
+		if e.IsGroup {
+			if err := e.expand(); err != nil {
+				return false, err
+			}
+			e.group.name = e.Filename
+			c, err := e.group.convertLegacy()
+			if err != nil {
+				return false, err
+			}
+			if c {
+				e.HasCRC = false
+				changed = true
+			}
+			continue
+		}
+		if e.Filename == C4CFN_ScenarioCore {
+			c, err := e.convertScenario()
+			if err != nil {
+				return false, err
+			}
+			changed = changed || c
+		}
+	}
+	return changed, nil
+}
+
+// convertScenario converts the [Definitions] of a Scenario.txt entry.
+func (e *builderEntry) convertScenario() (bool, error) {
+	r, err := e.open()
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+	f, err := core.Parse(r)
+	if err != nil {
+		return false, err
+	}
+	changed := ConvertLegacyScenario(f)
+	if !changed {
+		return false, nil
+	}
+	data := f.Bytes()
+	e.Size = len(data)
+	e.HasCRC = false
+	e.open = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+	e.source = nil
+	return true, nil
+}
+
+// ConvertLegacyScenario converts the definition references in the
+// [Definitions] section of a parsed Scenario.txt with ConvertLegacyPath.
+// It reports whether any value changed.
+func ConvertLegacyScenario(f *core.File) bool {
+	s := f.Section("Definitions")
+	if s == nil {
+		return false
+	}
+	changed := false
+	for i := range s.Lines {
+		l := &s.Lines[i]
+		if !strings.HasPrefix(l.Key, "Definition") {
+			continue
+		}
+		if v := ConvertLegacyPath(l.Value); v != l.Value {
+			l.Value = v
+			changed = true
+		}
+	}
+	return changed
+}
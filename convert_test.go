@@ -0,0 +1,108 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestConvertLegacyName(t *testing.T) {
+	for _, c := range []struct{ name, want string }{
+		{"Objects.c4d", "Objects.ocd"},
+		{"Arena.C4S", "Arena.ocs"},
+		{"PXS.c4b", "PXS.ocb"},
+		{"Script.c", "Script.c"},
+		{"Objects.ocd", "Objects.ocd"},
+	} {
+		if got, _ := ConvertLegacyName(c.name); got != c.want {
+			t.Errorf("ConvertLegacyName(%q) = %q, want %q", c.name, got, c.want)
+		}
+	}
+	if got, want := ConvertLegacyPath(`Knights.c4f\Objects.c4d/Script.c`), `Knights.ocf\Objects.ocd/Script.c`; got != want {
+		t.Errorf("ConvertLegacyPath: got %q, want %q", got, want)
+	}
+	if p := DetectProfile("Arena.c4s"); p != ClonkRage {
+		t.Errorf("DetectProfile(Arena.c4s) = %v", p)
+	}
+	if p := DetectProfile("Arena.ocs"); p != OpenClonk {
+		t.Errorf("DetectProfile(Arena.ocs) = %v", p)
+	}
+	if p := DetectProfile("Script.c"); p != nil {
+		t.Errorf("DetectProfile(Script.c) = %v", p)
+	}
+	less := NameLess("Clonk.c4d")
+	if !less("DefCore.txt", "Graphics.png") || !less("ActMap.txt", "Script.c") {
+		t.Error("Clonk Rage definitions aren't sorted with C4FLS_Def")
+	}
+}
+
+func TestConvertLegacy(t *testing.T) {
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	scenario := "[Head]\r\nTitle=Test\r\n\r\n[Definitions]\r\nDefinition1=Objects.c4d\r\nDefinition2=Knights.c4f\\Knights.c4d\r\n"
+	b := NewBuilder("Test.c4s")
+	b.AddFile(Entry{Filename: "PXS.c4b", Size: 3}, stringFile("PXS"))
+	b.AddFile(Entry{Filename: "Scenario.txt", Size: len(scenario)}, stringFile(scenario))
+	objects := b.AddGroup(Entry{Filename: "Objects.c4d"})
+	clonk := objects.AddGroup(Entry{Filename: "Clonk.c4d"})
+	clonk.AddFile(Entry{Filename: "DefCore.txt", Size: 9}, stringFile("[DefCore]"))
+	var legacy bytes.Buffer
+	check(b.Pack(&legacy))
+	src, err := OpenReaderAt(bytes.NewReader(legacy.Bytes()), int64(legacy.Len()))
+	check(err)
+
+	var buf bytes.Buffer
+	check(ConvertLegacy(&buf, "Test.ocs", src))
+	g, err := OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	check(err)
+	checkNames(t, "root", entryNames(g), "Scenario.txt", "Objects.ocd", "PXS.ocb")
+	_, err = g.Lookup("Objects.ocd/Clonk.ocd/DefCore.txt")
+	check(err)
+	f, err := g.Open("Scenario.txt")
+	check(err)
+	data, err := ioutil.ReadAll(f)
+	check(err)
+	want := "[Head]\r\nTitle=Test\r\n\r\n[Definitions]\r\nDefinition1=Objects.ocd\r\nDefinition2=Knights.ocf\\Knights.ocd\r\n"
+	if string(data) != want {
+		t.Errorf("Scenario.txt: got %q, want %q", data, want)
+	}
+}
+
+func TestConvertLegacyCollision(t *testing.T) {
+	b := NewBuilder("Test.c4s")
+	objects := b.AddGroup(Entry{Filename: "Objects.c4d"})
+	objects.AddFile(Entry{Filename: "Foo.c4d", Size: 3}, stringFile("old"))
+	objects.AddFile(Entry{Filename: "Foo.ocd", Size: 3}, stringFile("new"))
+	var legacy bytes.Buffer
+	if err := b.Pack(&legacy); err != nil {
+		t.Fatal(err)
+	}
+	src, err := OpenReaderAt(bytes.NewReader(legacy.Bytes()), int64(legacy.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ConvertLegacy(ioutil.Discard, "Test.ocs", src)
+	if !errors.Is(err, ErrEntryExists) || !strings.Contains(err.Error(), "Foo.c4d") || !strings.Contains(err.Error(), "Foo.ocd") {
+		t.Errorf("expected ErrEntryExists naming both entries, got %v", err)
+	}
+}
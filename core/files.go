@@ -0,0 +1,381 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package core
+
+import "io"
+
+// Typed structs for the engine's core files. They only contain the commonly
+// used keys, all other keys are kept when writing files back with Marshal.
+// Values like "100,0,0,250" (standard, random, minimum, maximum) are
+// decoded as integer slices.
+
+// Decode parses r and unmarshals it into v. The returned File can be used
+// with Marshal to write modifications back.
+func Decode(r io.Reader, v interface{}) (*File, error) {
+	f, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := Unmarshal(f, v); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Scenario is Scenario.txt (C4Scenario).
+type Scenario struct {
+	Head        ScenarioHead
+	Definitions ScenarioDefinitions
+	Game        ScenarioGame
+	Player1     ScenarioPlayer
+	Player2     ScenarioPlayer
+	Player3     ScenarioPlayer
+	Player4     ScenarioPlayer
+	Landscape   ScenarioLandscape
+	Weather     ScenarioWeather
+	Animals     ScenarioAnimals
+	Environment ScenarioEnvironment
+}
+
+type ScenarioHead struct {
+	Icon            int
+	Title           string
+	Version         []int
+	Difficulty      int
+	MaxPlayer       int
+	MinPlayer       int
+	MaxPlayerLeague int
+	SaveGame        bool
+	Replay          bool
+	Film            int
+	NoInitialize    bool
+	RandomSeed      int
+	Engine          string
+	MissionAccess   string
+	Secret          bool
+}
+
+type ScenarioDefinitions struct {
+	LocalOnly   bool
+	Definition1 string
+	Definition2 string
+	Definition3 string
+	Definition4 string
+	Definition5 string
+	Definition6 string
+	Definition7 string
+	Definition8 string
+	Definition9 string
+	SkipDefs    string
+}
+
+// List returns all non-empty DefinitionN values.
+func (d *ScenarioDefinitions) List() []string {
+	var defs []string
+	for _, def := range []string{d.Definition1, d.Definition2, d.Definition3, d.Definition4, d.Definition5, d.Definition6, d.Definition7, d.Definition8, d.Definition9} {
+		if def != "" {
+			defs = append(defs, def)
+		}
+	}
+	return defs
+}
+
+type ScenarioGame struct {
+	Mode       string
+	Goals      string // id=count lists, separated by ';'
+	Rules      string
+	FoWEnabled bool
+}
+
+type ScenarioPlayer struct {
+	Wealth             []int
+	Position           []int
+	Crew               string
+	Buildings          string
+	Vehicles           string
+	Material           string
+	Knowledge          string
+	HomeBaseMaterial   string
+	HomeBaseProduction string
+	Magic              string
+}
+
+type ScenarioLandscape struct {
+	Vegetation      string
+	VegetationLevel []int
+	InEarth         string
+	InEarthLevel    []int
+	Sky             string
+	BottomOpen      int
+	TopOpen         int
+	LeftOpen        int
+	RightOpen       int
+	AutoScanSides   bool
+	MapWidth        []int
+	MapHeight       []int
+	MapZoom         []int
+	Material        string
+	Liquid          string
+	LiquidLevel     []int
+	ExactLandscape  bool
+	Gravity         []int
+}
+
+type ScenarioWeather struct {
+	Climate       []int
+	StartSeason   []int
+	YearSpeed     []int
+	Rain          []int
+	Wind          []int
+	Precipitation string
+	Lightning     []int
+}
+
+type ScenarioAnimals struct {
+	Animal string
+	Nest   string
+}
+
+type ScenarioEnvironment struct {
+	Objects string
+}
+
+// DefCore is DefCore.txt (C4DefCore).
+type DefCore struct {
+	DefCore DefCoreSection
+}
+
+type DefCoreSection struct {
+	ID             string `ini:"id"`
+	Version        []int
+	Category       string // C4D_* flags separated by '|'
+	Width          int
+	Height         int
+	Offset         []int
+	Value          int
+	Mass           int
+	Components     string
+	SolidMask      []int
+	TopFace        []int
+	Picture        []int
+	Vertices       int
+	VertexX        []int
+	VertexY        []int
+	VertexCNAT     []int
+	VertexFriction []int
+	Rotate         int
+	Construction   bool
+	CrewMember     bool
+	ColorByOwner   bool
+	Exclusive      bool
+}
+
+// Player is Player.txt (C4PlayerInfoCore).
+type Player struct {
+	Player      PlayerSection
+	Preferences PlayerPreferences
+}
+
+type PlayerSection struct {
+	Name             string
+	Comment          string
+	Rank             int
+	RankName         string
+	Score            int
+	Rounds           int
+	RoundsWon        int
+	RoundsLost       int
+	TotalPlayingTime int
+}
+
+type PlayerPreferences struct {
+	Color            int
+	ColorDw          uint32
+	AlternateColorDw uint32
+}
+
+// Game is Game.txt, the runtime data stored in savegames.
+type Game struct {
+	Game GameSection
+}
+
+type GameSection struct {
+	Time                   int
+	Frame                  int
+	Tick10                 int
+	Tick35                 int
+	Tick255                int
+	ObjectEnumerationIndex int
+	StartupPlayerCount     int
+	StartupTeamCount       int
+	NextMission            string
+	NextMissionText        string
+	NextMissionDesc        string
+}
+
+// Teams is Teams.txt (C4TeamList).
+type Teams struct {
+	Teams TeamsSection
+	Team  []Team
+}
+
+type TeamsSection struct {
+	Active               bool
+	Custom               bool
+	AllowHostilityChange bool
+	AllowTeamSwitch      bool
+	AutoGenerateTeams    bool
+	TeamDistribution     string
+	TeamColors           bool
+	MaxScriptPlayers     int
+}
+
+type Team struct {
+	ID        int `ini:"id"`
+	Name      string
+	Color     uint32
+	IconSpec  string
+	MaxPlayer int
+}
+
+// Update is AutoUpdate.txt (C4UpdatePackageCore) of update groups.
+type Update struct {
+	Update UpdateSection
+}
+
+type UpdateSection struct {
+	RequireVersion []int // engine version, 0 for any
+	Name           string
+	DestPath       string   // path of the updated group
+	GrpUpdate      int      // 1 for group updates, 0 for file replacements
+	TargetCount    int      // number of GrpChks1 values
+	GrpChks1       []uint32 // checksums of groups which can be updated
+	GrpChks2       uint32   // checksum of the updated group
+}
+
+// ParameterDefs is ParameterDefs.txt (C4ScenarioParameterDefs). Each
+// [ParameterDef] section is followed by an [Options] section and the
+// [Option] sections belonging to it.
+type ParameterDefs struct {
+	Defs []ParameterDef
+}
+
+type ParameterDef struct {
+	Name        string
+	Description string
+	ID          string
+	Type        string
+	Default     int
+	LeagueValue int
+	Achievement string
+	Options     []ParameterOption `ini:"-"`
+}
+
+type ParameterOption struct {
+	Name        string
+	Description string
+	Value       int
+}
+
+// UnmarshalCore implements Unmarshaler.
+func (p *ParameterDefs) UnmarshalCore(f *File) error {
+	p.Defs = nil
+	for _, s := range f.Sections {
+		switch s.Name {
+		case "ParameterDef":
+			p.Defs = append(p.Defs, ParameterDef{})
+			if err := decodeSection(s, reflectValue(&p.Defs[len(p.Defs)-1])); err != nil {
+				return err
+			}
+		case "Option":
+			if len(p.Defs) == 0 {
+				continue
+			}
+			def := &p.Defs[len(p.Defs)-1]
+			def.Options = append(def.Options, ParameterOption{})
+			if err := decodeSection(s, reflectValue(&def.Options[len(def.Options)-1])); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalCore implements Marshaler.
+func (p *ParameterDefs) MarshalCore(f *File) error {
+	// Collect the existing sections of each definition.
+	type defSections struct {
+		def, options *Section
+		option       []*Section
+	}
+	var existing []*defSections
+	for _, s := range f.Sections {
+		switch s.Name {
+		case "ParameterDef":
+			existing = append(existing, &defSections{def: s})
+		case "Options":
+			if len(existing) > 0 {
+				existing[len(existing)-1].options = s
+			}
+		case "Option":
+			if len(existing) > 0 {
+				d := existing[len(existing)-1]
+				d.option = append(d.option, s)
+			}
+		}
+	}
+	for i := range p.Defs {
+		def := &p.Defs[i]
+		if i >= len(existing) {
+			d := &defSections{def: f.AddSection("ParameterDef")}
+			if len(def.Options) > 0 {
+				d.options = f.AddSection("Options")
+			}
+			existing = append(existing, d)
+		}
+		d := existing[i]
+		if err := encodeSection(d.def, reflectValue(def)); err != nil {
+			return err
+		}
+		for j := range def.Options {
+			if j >= len(d.option) {
+				after := d.option
+				if len(after) == 0 {
+					if d.options == nil {
+						d.options = f.insertSectionAfter([]*Section{d.def}, "Options")
+					}
+					after = []*Section{d.options}
+				}
+				d.option = append(d.option, f.insertSectionAfter(after, "Option"))
+			}
+			if err := encodeSection(d.option[j], reflectValue(&def.Options[j])); err != nil {
+				return err
+			}
+		}
+		for _, s := range d.option[len(def.Options):] {
+			f.RemoveSection(s)
+		}
+	}
+	for _, d := range existing[len(p.Defs):] {
+		f.RemoveSection(d.def)
+		if d.options != nil {
+			f.RemoveSection(d.options)
+		}
+		for _, s := range d.option {
+			f.RemoveSection(s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package core
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestScenario(t *testing.T) {
+	var scen Scenario
+	f, err := Decode(strings.NewReader(testScenario), &scen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scen.Head.Icon != 20 || scen.Head.MaxPlayer != 4 || scen.Head.Title != "Tutorial" {
+		t.Errorf("unexpected head: %+v", scen.Head)
+	}
+	if defs := scen.Definitions.List(); len(defs) != 2 || defs[1] != "Decoration.ocd" {
+		t.Errorf("unexpected definitions: %v", defs)
+	}
+	if w := scen.Landscape.MapWidth; len(w) != 4 || w[0] != 100 || w[3] != 250 {
+		t.Errorf("unexpected MapWidth: %v", w)
+	}
+
+	scen.Head.Icon = 21
+	scen.Head.MinPlayer = 2
+	if err := Marshal(f, &scen); err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(testScenario, "Icon=20", "Icon=21", 1)
+	want = strings.Replace(want, "UnknownKey=keep me\r\n", "UnknownKey=keep me\r\nMinPlayer=2\r\n", 1)
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("got\n%q\nwant\n%q", got, want)
+	}
+
+	var def DefCore
+	_, err = Decode(strings.NewReader("[DefCore]\nid=Rock\nWidth=abc\n"), &def)
+	if !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("expected ErrInvalidValue, got %v", err)
+	}
+}
+
+func TestBoolFlags(t *testing.T) {
+	var def DefCore
+	f, err := Decode(strings.NewReader("[DefCore]\nid=Rock\nExclusive=2\nConstruction=0\n"), &def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !def.DefCore.Exclusive || def.DefCore.Construction {
+		t.Fatalf("unexpected flags: %+v", def.DefCore)
+	}
+	def.DefCore.Construction = true
+	if err := Marshal(f, &def); err != nil {
+		t.Fatal(err)
+	}
+	want := "[DefCore]\nid=Rock\nExclusive=2\nConstruction=1\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := Decode(strings.NewReader("[DefCore]\nExclusive=yes\n"), &def); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("expected ErrInvalidValue, got %v", err)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	var teams Teams
+	f, err := Decode(strings.NewReader("[Teams]\nActive=true\n[Team]\nid=1\nName=Red\n[Team]\nid=2\nName=Blue\nColor=255\n"), &teams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !teams.Teams.Active || len(teams.Team) != 2 || teams.Team[1].Name != "Blue" || teams.Team[1].Color != 255 {
+		t.Fatalf("unexpected teams: %+v", teams)
+	}
+	teams.Team = append(teams.Team[1:], Team{ID: 3, Name: "Green"})
+	if err := Marshal(f, &teams); err != nil {
+		t.Fatal(err)
+	}
+	want := "[Teams]\nActive=true\n[Team]\nid=2\nName=Blue\nColor=255\n[Team]\nid=3\nName=Green\nColor=0\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParameterDefs(t *testing.T) {
+	const input = "[ParameterDef]\n" +
+		"Name=$Difficulty$\n" +
+		"ID=Difficulty\n" +
+		"Default=1\n" +
+		"\t[Options]\n" +
+		"\t\t[Option]\n" +
+		"\t\tName=$Easy$\n" +
+		"\t\tValue=1\n" +
+		"\t\t[Option]\n" +
+		"\t\tName=$Hard$\n" +
+		"\t\tValue=3\n" +
+		"[ParameterDef]\n" +
+		"ID=Rounds\n"
+	var defs ParameterDefs
+	f, err := Decode(strings.NewReader(input), &defs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defs.Defs) != 2 || len(defs.Defs[0].Options) != 2 || defs.Defs[0].Options[1].Value != 3 || defs.Defs[1].ID != "Rounds" {
+		t.Fatalf("unexpected definitions: %+v", defs)
+	}
+	if got := string(f.Bytes()); got != input {
+		t.Errorf("round trip changed file:\n%q", got)
+	}
+
+	defs.Defs[0].Options = append(defs.Defs[0].Options, ParameterOption{Name: "$Insane$", Value: 5})
+	defs.Defs[1].Options = []ParameterOption{{Name: "1", Value: 1}}
+	if err := Marshal(f, &defs); err != nil {
+		t.Fatal(err)
+	}
+	var again ParameterDefs
+	if err := Unmarshal(f, &again); err != nil {
+		t.Fatal(err)
+	}
+	if len(again.Defs) != 2 || len(again.Defs[0].Options) != 3 || again.Defs[0].Options[2].Value != 5 || len(again.Defs[1].Options) != 1 {
+		t.Errorf("unexpected definitions after Marshal: %+v\n%s", again, f.Bytes())
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ProblemKind classifies translation problems.
+type ProblemKind int
+
+const (
+	MissingFile      ProblemKind = iota // a language lacks a localized file
+	MissingKey                          // a string table lacks a key
+	ExtraKey                            // a string table has a key the reference lacks
+	UnknownReference                    // a $Key$ reference has no string table entry
+)
+
+func (k ProblemKind) String() string {
+	switch k {
+	case MissingFile:
+		return "missing file"
+	case MissingKey:
+		return "missing key"
+	case ExtraKey:
+		return "extra key"
+	case UnknownReference:
+		return "unknown reference"
+	}
+	return "unknown problem"
+}
+
+// TranslationProblem is reported by CheckTranslations.
+type TranslationProblem struct {
+	Kind ProblemKind
+	File string // slash-separated path of the affected file
+	Lang string
+	Key  string // string table key, if applicable
+}
+
+func (p TranslationProblem) String() string {
+	s := fmt.Sprintf("%s: %s", p.File, p.Kind)
+	if p.Key != "" {
+		s += " " + p.Key
+	}
+	if p.Lang != "" {
+		s += " (" + p.Lang + ")"
+	}
+	return s
+}
+
+// TranslationOptions configures CheckTranslations.
+type TranslationOptions struct {
+	// Languages which every localized resource must exist in. By default,
+	// all languages found anywhere in the tree are required.
+	Languages []string
+	// Reference is the language whose string tables are complete. Keys
+	// missing from the reference table are reported as extra keys in
+	// other languages. If empty or missing in a group, the keys of all
+	// tables of the group are combined and no extra keys are reported.
+	Reference string
+}
+
+var (
+	localizedFileRegexp = regexp.MustCompile(`^(StringTbl|Desc|Title)([A-Z]{2})\.txt$`)
+	referenceRegexp     = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)\$`)
+)
+
+// groupTranslations contains the localized files of a single group.
+type groupTranslations struct {
+	dir     string
+	files   map[string]map[string]bool // kind -> language -> exists
+	titles  map[string]bool            // languages in Title.txt
+	tables  map[string]map[string]string
+	sources []string // files which may contain $Key$ references
+}
+
+// CheckTranslations compares localized files (StringTbl<lang>.txt,
+// Desc<lang>.txt and Title<lang>.txt or Title.txt) across languages in all
+// directories of fsys. It also reports $Key$ references in scripts and
+// ParameterDefs.txt without string table entry. opts may be nil.
+func CheckTranslations(fsys fs.FS, opts *TranslationOptions) ([]TranslationProblem, error) {
+	if opts == nil {
+		opts = &TranslationOptions{}
+	}
+	var groups []*groupTranslations
+	allLangs := make(map[string]bool)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		g, err := readGroupTranslations(fsys, p)
+		if err != nil {
+			return err
+		}
+		for _, langs := range g.files {
+			for lang := range langs {
+				allLangs[lang] = true
+			}
+		}
+		for lang := range g.titles {
+			allLangs[lang] = true
+		}
+		groups = append(groups, g)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	langs := opts.Languages
+	if langs == nil {
+		for lang := range allLangs {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+	}
+
+	var problems []TranslationProblem
+	for _, g := range groups {
+		ps, err := g.check(fsys, langs, opts.Reference)
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, ps...)
+	}
+	return problems, nil
+}
+
+func readGroupTranslations(fsys fs.FS, dir string) (*groupTranslations, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	g := &groupTranslations{
+		dir:    dir,
+		files:  make(map[string]map[string]bool),
+		titles: make(map[string]bool),
+		tables: make(map[string]map[string]string),
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		p := path.Join(dir, name)
+		if m := localizedFileRegexp.FindStringSubmatch(name); m != nil {
+			kind, lang := m[1], m[2]
+			if g.files[kind] == nil {
+				g.files[kind] = make(map[string]bool)
+			}
+			g.files[kind][lang] = true
+			switch kind {
+			case "StringTbl":
+				data, err := fs.ReadFile(fsys, p)
+				if err != nil {
+					return nil, err
+				}
+				if g.tables[lang], err = ParseStringTable(bytes.NewReader(data)); err != nil {
+					return nil, err
+				}
+			case "Desc", "Title":
+				g.sources = append(g.sources, p)
+			}
+			continue
+		}
+		switch {
+		case name == "Title.txt":
+			data, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return nil, err
+			}
+			for lang := range LanguageStrings(string(data)) {
+				g.titles[lang] = true
+			}
+			g.sources = append(g.sources, p)
+		case strings.HasSuffix(name, ".c") || name == "ParameterDefs.txt":
+			g.sources = append(g.sources, p)
+		}
+	}
+	return g, nil
+}
+
+func (g *groupTranslations) check(fsys fs.FS, langs []string, reference string) ([]TranslationProblem, error) {
+	var problems []TranslationProblem
+	file := func(name string) string {
+		return path.Join(g.dir, name)
+	}
+
+	// Missing localized files.
+	for _, kind := range []string{"StringTbl", "Desc", "Title"} {
+		have := g.files[kind]
+		if kind == "Title" && len(g.titles) > 0 {
+			have = make(map[string]bool)
+			for lang := range g.files[kind] {
+				have[lang] = true
+			}
+			for lang := range g.titles {
+				have[lang] = true
+			}
+		}
+		if len(have) == 0 {
+			continue
+		}
+		for _, lang := range langs {
+			if !have[lang] {
+				problems = append(problems, TranslationProblem{Kind: MissingFile, File: file(kind + lang + ".txt"), Lang: lang})
+			}
+		}
+	}
+
+	// Missing and extra keys.
+	var tableLangs []string
+	all := make(map[string]string)
+	for lang, table := range g.tables {
+		tableLangs = append(tableLangs, lang)
+		for key, value := range table {
+			all[key] = value
+		}
+	}
+	sort.Strings(tableLangs)
+	ref, hasRef := g.tables[reference]
+	if !hasRef {
+		ref = all
+	}
+	for _, lang := range tableLangs {
+		table := g.tables[lang]
+		for _, key := range sortedKeys(ref) {
+			if _, ok := table[key]; !ok {
+				problems = append(problems, TranslationProblem{Kind: MissingKey, File: file("StringTbl" + lang + ".txt"), Lang: lang, Key: key})
+			}
+		}
+		if !hasRef || lang == reference {
+			continue
+		}
+		for _, key := range sortedKeys(table) {
+			if _, ok := ref[key]; !ok {
+				problems = append(problems, TranslationProblem{Kind: ExtraKey, File: file("StringTbl" + lang + ".txt"), Lang: lang, Key: key})
+			}
+		}
+	}
+
+	// References without entry in any string table. Keys missing in only
+	// some languages are reported above.
+	for _, source := range g.sources {
+		data, err := fs.ReadFile(fsys, source)
+		if err != nil {
+			return nil, err
+		}
+		seen := make(map[string]bool)
+		for _, m := range referenceRegexp.FindAllStringSubmatch(string(data), -1) {
+			key := m[1]
+			if _, ok := all[key]; !ok && !seen[key] {
+				problems = append(problems, TranslationProblem{Kind: UnknownReference, File: source, Key: key})
+			}
+			seen[key] = true
+		}
+	}
+	return problems, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,68 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package core
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestCheckTranslations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Title.txt":                        {Data: []byte("DE:Titel\nUS:Title\n")},
+		"DescDE.txt":                       {Data: []byte("Beschreibung")},
+		"StringTblDE.txt":                  {Data: []byte("A=a\nB=b\nOnlyDE=x\n")},
+		"StringTblUS.txt":                  {Data: []byte("A=a\nC=c\n")},
+		"Script.c":                         {Data: []byte(`Log("$A$ $Missing$ $A$ costs 5$");`)},
+		"Objects.ocd/Rock.ocd/TitleDE.txt": {Data: []byte("Stein")},
+		"Objects.ocd/Rock.ocd/Script.c":    {Data: []byte(`Message("$Hello$");`)},
+	}
+	problems, err := CheckTranslations(fsys, &TranslationOptions{Reference: "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"DescUS.txt: missing file (US)",
+		"StringTblDE.txt: missing key C (DE)",
+		"StringTblDE.txt: extra key B (DE)",
+		"StringTblDE.txt: extra key OnlyDE (DE)",
+		"Script.c: unknown reference Missing",
+		"Objects.ocd/Rock.ocd/TitleUS.txt: missing file (US)",
+		"Objects.ocd/Rock.ocd/Script.c: unknown reference Hello",
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.String())
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %q, want %q", got[i], want[i])
+		}
+	}
+
+	// Without reference, only missing keys are reported.
+	problems, err = CheckTranslations(fsys, &TranslationOptions{Languages: []string{"DE", "US"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range problems {
+		if p.Kind == ExtraKey {
+			t.Errorf("unexpected problem %s", p)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package core reads and writes OpenClonk's ini-style core files such as
+// Scenario.txt and DefCore.txt.
+//
+// The format consists of [Section] headers followed by Key=Value lines.
+// Parsing keeps comments, unknown keys and the order of all lines so that
+// files can be modified without losing information.
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+)
+
+const bom = "\xef\xbb\xbf"
+
+// File is a parsed core file.
+type File struct {
+	// Preamble contains lines before the first section header.
+	Preamble []Line
+	Sections []*Section
+
+	crlf bool // use \r\n line endings
+	bom  bool // starts with an UTF-8 byte order mark
+}
+
+// Section is a [Name] header and its lines. Section names need not be
+// unique, e.g. Teams.txt contains a [Team] section for each team.
+type Section struct {
+	Name  string
+	Lines []Line
+
+	indent string
+}
+
+// Line is a Key=Value pair. Comments, blank lines and lines the parser
+// doesn't understand have an empty Key and are kept in Raw.
+type Line struct {
+	Key, Value string
+	Raw        string
+
+	indent string
+}
+
+// Parse reads a core file. Parsing is lenient like in the engine: invalid
+// lines are kept but otherwise ignored.
+func Parse(r io.Reader) (*File, error) {
+	f := &File{}
+	lines := &f.Preamble
+	br := bufio.NewReader(r)
+	first := true
+	for {
+		text, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if text == "" && err == io.EOF {
+			break
+		}
+		text = strings.TrimSuffix(text, "\n")
+		if first {
+			if strings.HasPrefix(text, bom) {
+				text = text[len(bom):]
+				f.bom = true
+			}
+			first = false
+		}
+		if strings.HasSuffix(text, "\r") {
+			text = text[:len(text)-1]
+			f.crlf = true
+		}
+		trimmed := strings.TrimSpace(text)
+		indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+		switch {
+		case isComment(trimmed):
+			*lines = append(*lines, Line{Raw: text})
+		case strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]"):
+			s := &Section{Name: strings.TrimSpace(trimmed[1 : len(trimmed)-1]), indent: indent}
+			f.Sections = append(f.Sections, s)
+			lines = &s.Lines
+		default:
+			i := strings.IndexByte(trimmed, '=')
+			if i <= 0 {
+				*lines = append(*lines, Line{Raw: text})
+				break
+			}
+			*lines = append(*lines, Line{
+				Key:    strings.TrimSpace(trimmed[:i]),
+				Value:  strings.TrimSpace(trimmed[i+1:]),
+				indent: indent,
+			})
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return f, nil
+}
+
+func isComment(line string) bool {
+	return line == "" || line[0] == '#' || line[0] == ';' || strings.HasPrefix(line, "//")
+}
+
+// WriteTo serializes the file. Line endings and byte order mark of the
+// parsed file are kept, new files use \r\n like the engine.
+func (f *File) WriteTo(w io.Writer) (int64, error) {
+	nl := "\n"
+	if f.crlf {
+		nl = "\r\n"
+	}
+	var buf bytes.Buffer
+	if f.bom {
+		buf.WriteString(bom)
+	}
+	writeLines := func(lines []Line) {
+		for _, l := range lines {
+			if l.Key == "" {
+				buf.WriteString(l.Raw)
+			} else {
+				buf.WriteString(l.indent + l.Key + "=" + l.Value)
+			}
+			buf.WriteString(nl)
+		}
+	}
+	writeLines(f.Preamble)
+	for _, s := range f.Sections {
+		buf.WriteString(s.indent + "[" + s.Name + "]" + nl)
+		writeLines(s.Lines)
+	}
+	return buf.WriteTo(w)
+}
+
+// Bytes returns the serialized file.
+func (f *File) Bytes() []byte {
+	var buf bytes.Buffer
+	f.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// NewFile returns an empty file which uses \r\n line endings.
+func NewFile() *File {
+	return &File{crlf: true}
+}
+
+// Section returns the first section with the given name or nil.
+func (f *File) Section(name string) *Section {
+	for _, s := range f.Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// AllSections returns all sections with the given name.
+func (f *File) AllSections(name string) []*Section {
+	var sections []*Section
+	for _, s := range f.Sections {
+		if s.Name == name {
+			sections = append(sections, s)
+		}
+	}
+	return sections
+}
+
+// AddSection appends a new section.
+func (f *File) AddSection(name string) *Section {
+	s := &Section{Name: name}
+	f.Sections = append(f.Sections, s)
+	return s
+}
+
+// RemoveSection removes the section s from the file.
+func (f *File) RemoveSection(s *Section) {
+	for i := range f.Sections {
+		if f.Sections[i] == s {
+			f.Sections = append(f.Sections[:i], f.Sections[i+1:]...)
+			return
+		}
+	}
+}
+
+// Get returns the value of the first line with the given key.
+func (s *Section) Get(key string) (value string, ok bool) {
+	for _, l := range s.Lines {
+		if l.Key == key {
+			return l.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set changes the value of an existing key or appends a new line. New lines
+// are inserted before trailing blank lines and comments.
+func (s *Section) Set(key, value string) {
+	for i := range s.Lines {
+		if s.Lines[i].Key == key {
+			s.Lines[i].Value = value
+			return
+		}
+	}
+	i := len(s.Lines)
+	for i > 0 && s.Lines[i-1].Key == "" {
+		i--
+	}
+	s.Lines = append(s.Lines, Line{})
+	copy(s.Lines[i+1:], s.Lines[i:])
+	indent := s.indent
+	if i > 0 {
+		indent = s.Lines[i-1].indent
+	}
+	s.Lines[i] = Line{Key: key, Value: value, indent: indent}
+}
+
+// Delete removes all lines with the given key.
+func (s *Section) Delete(key string) {
+	lines := s.Lines[:0]
+	for _, l := range s.Lines {
+		if l.Key != key {
+			lines = append(lines, l)
+		}
+	}
+	s.Lines = lines
+}
@@ -0,0 +1,73 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+const testScenario = "\xef\xbb\xbf# Generated\r\n" +
+	"[Head]\r\n" +
+	"Icon=20\r\n" +
+	"Title=Tutorial\r\n" +
+	"Version=8,0\r\n" +
+	"MaxPlayer=4\r\n" +
+	"UnknownKey=keep me\r\n" +
+	"\r\n" +
+	"[Definitions]\r\n" +
+	"Definition1=Objects.ocd\r\n" +
+	"Definition2=Decoration.ocd\r\n" +
+	"\r\n" +
+	"[Landscape]\r\n" +
+	"  MapWidth=100,0,0,250\r\n" +
+	"  ; comment\r\n" +
+	"  Sky=Clouds2\r\n" +
+	"\r\n" +
+	"[Custom]\r\n" +
+	"Foo=Bar\r\n"
+
+func TestRoundTrip(t *testing.T) {
+	f, err := Parse(strings.NewReader(testScenario))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(f.Bytes()); got != testScenario {
+		t.Errorf("round trip changed file:\n%q\nwant\n%q", got, testScenario)
+	}
+	if v, _ := f.Section("Landscape").Get("Sky"); v != "Clouds2" {
+		t.Errorf("Sky: got %q", v)
+	}
+}
+
+func TestSet(t *testing.T) {
+	f, err := Parse(strings.NewReader("[Head]\nTitle=Foo\n\n[Game]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := f.Section("Head")
+	head.Set("Title", "Bar")
+	head.Set("Icon", "3")
+	f.Section("Game").Set("Goals", "Melee=1")
+	f.AddSection("Landscape").Set("Sky", "Default")
+	want := "[Head]\nTitle=Bar\nIcon=3\n\n[Game]\nGoals=Melee=1\n[Landscape]\nSky=Default\n"
+	if got := string(f.Bytes()); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	head.Delete("Title")
+	if _, ok := head.Get("Title"); ok {
+		t.Error("Title not deleted")
+	}
+}
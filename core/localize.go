@@ -0,0 +1,197 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+)
+
+// Localized contains the resources of a group in the preferred language.
+type Localized struct {
+	Title   string            // empty if there is no title file
+	Desc    string            // empty if there is no description
+	Strings map[string]string // string table, empty if there is none
+
+	// Languages the resources were taken from, empty if not localized.
+	TitleLang, DescLang, StringsLang string
+}
+
+// Localize resolves the localized resources of the group fsys like the
+// engine, e.g. for a scenario or definition. langs lists language codes in
+// order of preference, e.g. ["DE", "US"].
+//
+// The title is read from Title<lang>.txt, falling back to Title.txt, which
+// may contain one "<lang>:<title>" line per language. The description is read
+// from Desc<lang>.txt and the string table from StringTbl<lang>.txt. $Key$
+// references in title and description are replaced with strings from the
+// table.
+func Localize(fsys fs.FS, langs []string) (*Localized, error) {
+	l := &Localized{Strings: make(map[string]string)}
+
+	if data, lang, err := readLocalized(fsys, "StringTbl%s.txt", langs); err != nil {
+		return nil, err
+	} else if data != nil {
+		l.StringsLang = lang
+		if l.Strings, err = ParseStringTable(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+	}
+
+	data, lang, err := readLocalized(fsys, "Title%s.txt", langs)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		data, err = readOptional(fsys, "Title.txt")
+		if err != nil {
+			return nil, err
+		}
+		lang, l.Title = LanguageString(string(data), langs)
+	} else {
+		l.Title = strings.TrimSpace(string(data))
+	}
+	l.TitleLang = lang
+	l.Title = ReplaceStrings(l.Title, l.Strings)
+
+	data, lang, err = readLocalized(fsys, "Desc%s.txt", langs)
+	if err != nil {
+		return nil, err
+	}
+	l.DescLang = lang
+	l.Desc = ReplaceStrings(strings.TrimRight(string(data), "\r\n"), l.Strings)
+	return l, nil
+}
+
+// readLocalized reads the first existing file for the given languages. The
+// pattern contains %s for the language code.
+func readLocalized(fsys fs.FS, pattern string, langs []string) (data []byte, lang string, err error) {
+	for _, lang := range langs {
+		data, err := readOptional(fsys, strings.Replace(pattern, "%s", lang, 1))
+		if err != nil || data != nil {
+			return data, lang, err
+		}
+	}
+	return nil, "", nil
+}
+
+// readOptional reads a file, returning nil if it doesn't exist.
+func readOptional(fsys fs.FS, name string) ([]byte, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// LanguageStrings parses "<lang>:<text>" lines as used in Title.txt. The
+// keys are the two-letter language codes.
+func LanguageStrings(s string) map[string]string {
+	m := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if len(line) >= 3 && line[2] == ':' && isUpper(line[0]) && isUpper(line[1]) {
+			if _, ok := m[line[:2]]; !ok {
+				m[line[:2]] = line[3:]
+			}
+		}
+	}
+	return m
+}
+
+func isUpper(c byte) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+// LanguageString picks the preferred language from "<lang>:<text>" lines.
+// If s has no such lines, it is returned as a whole. If none of the
+// languages matches, the first line is used.
+func LanguageString(s string, langs []string) (lang, text string) {
+	m := LanguageStrings(s)
+	if len(m) == 0 {
+		return "", strings.TrimSpace(s)
+	}
+	for _, lang := range langs {
+		if text, ok := m[lang]; ok {
+			return lang, text
+		}
+	}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if len(line) >= 3 && line[2] == ':' {
+			if text, ok := m[line[:2]]; ok {
+				return line[:2], text
+			}
+		}
+	}
+	return "", ""
+}
+
+// ParseStringTable reads a StringTbl file with Key=Value lines. Lines
+// starting with '#' are comments.
+func ParseStringTable(r io.Reader) (map[string]string, error) {
+	f, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	table := make(map[string]string)
+	add := func(lines []Line) {
+		for _, l := range lines {
+			if l.Key != "" {
+				table[l.Key] = l.Value
+			}
+		}
+	}
+	// String tables have no sections, but be lenient.
+	add(f.Preamble)
+	for _, s := range f.Sections {
+		add(s.Lines)
+	}
+	return table, nil
+}
+
+// ReplaceStrings replaces $Key$ references with values from the string
+// table. Unknown keys are left alone.
+func ReplaceStrings(s string, table map[string]string) string {
+	if !strings.Contains(s, "$") {
+		return s
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(s, '$')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(s[start+1:], '$')
+		if end < 0 {
+			break
+		}
+		end += start + 1
+		if value, ok := table[s[start+1:end]]; ok {
+			b.WriteString(s[:start])
+			b.WriteString(value)
+			s = s[end+1:]
+		} else {
+			// Keep the first $, the second one may start a reference.
+			b.WriteString(s[:end])
+			s = s[end:]
+		}
+	}
+	b.WriteString(s)
+	return b.String()
+}
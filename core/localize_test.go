@@ -0,0 +1,84 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package core
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLocalize(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Title.txt":         {Data: []byte("DE:Höhlenrennen\r\nUS:Cave Race\r\n")},
+		"DescDE.txt":        {Data: []byte("Ein $Adj$ Rennen für $Players$.\r\n")},
+		"DescUS.txt":        {Data: []byte("A $Adj$ race.")},
+		"StringTblDE.txt":   {Data: []byte("# Kommentar\r\nAdj=schnelles\r\n")},
+		"StringTblUS.txt":   {Data: []byte("Adj=fast\n")},
+		"Sub/TitleUS.txt":   {Data: []byte("Only English\n")},
+		"Sub/StringTbl.txt": {Data: []byte("Unused=1\n")},
+	}
+	check := func(what, got, want string) {
+		t.Helper()
+		if got != want {
+			t.Errorf("%s: got %q, want %q", what, got, want)
+		}
+	}
+
+	l, err := Localize(fsys, []string{"DE", "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("title", l.Title, "Höhlenrennen")
+	check("desc", l.Desc, "Ein schnelles Rennen für $Players$.")
+	check("desc lang", l.DescLang, "DE")
+	check("string", l.Strings["Adj"], "schnelles")
+
+	l, err = Localize(fsys, []string{"FR", "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("title", l.Title, "Cave Race")
+	check("desc", l.Desc, "A fast race.")
+
+	l, err = Localize(fsys, []string{"FR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("fallback title", l.Title, "Höhlenrennen")
+	check("fallback desc", l.Desc, "")
+
+	sub, _ := fsys.Sub("Sub")
+	l, err = Localize(sub, []string{"DE", "US"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("sub title", l.Title, "Only English")
+	check("sub title lang", l.TitleLang, "US")
+}
+
+func TestReplaceStrings(t *testing.T) {
+	table := map[string]string{"A": "x", "B": "y"}
+	for in, want := range map[string]string{
+		"$A$ and $B$":  "x and y",
+		"cost: 5$ $A$": "cost: 5$ x",
+		"$$A$":         "$x",
+		"$unknown$":    "$unknown$",
+		"trailing $":   "trailing $",
+	} {
+		if got := ReplaceStrings(in, table); got != want {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+}
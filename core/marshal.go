@@ -0,0 +1,347 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidValue error = errors.New("core: invalid value")
+var ErrUnsupportedType error = errors.New("core: unsupported type")
+
+// Unmarshaler is implemented by types that decode themselves, e.g. because
+// sections are nested.
+type Unmarshaler interface {
+	UnmarshalCore(f *File) error
+}
+
+// Marshaler is the counterpart to Unmarshaler.
+type Marshaler interface {
+	MarshalCore(f *File) error
+}
+
+// Unmarshal decodes f into v, which must be a pointer to a struct. Each
+// field of v corresponds to a section: struct fields are filled from the
+// first section with that name, slice-of-struct fields from all of them.
+// The fields of section structs correspond to keys.
+//
+// Section and key names default to the field name and can be changed with
+// an `ini:"Name"` tag, `ini:"-"` skips the field. Supported value types are
+// strings, booleans, integers and slices of strings and integers, which are
+// comma-separated. Missing sections and keys leave fields untouched.
+func Unmarshal(f *File, v interface{}) error {
+	if u, ok := v.(Unmarshaler); ok {
+		return u.UnmarshalCore(f)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, ok := fieldName(rt.Field(i))
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		switch {
+		case fv.Kind() == reflect.Struct:
+			if s := f.Section(name); s != nil {
+				if err := decodeSection(s, fv); err != nil {
+					return err
+				}
+			}
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+			sections := f.AllSections(name)
+			slice := reflect.MakeSlice(fv.Type(), len(sections), len(sections))
+			for j, s := range sections {
+				if err := decodeSection(s, slice.Index(j)); err != nil {
+					return err
+				}
+			}
+			fv.Set(slice)
+		default:
+			return fmt.Errorf("%w: section %s has type %s", ErrUnsupportedType, name, fv.Type())
+		}
+	}
+	return nil
+}
+
+// Marshal writes the fields of v into f, see Unmarshal. Existing values are
+// replaced in place, other keys, sections and comments are kept. Keys which
+// are missing in f are only added if the field is non-zero. For slices of
+// sections, additional sections are appended and surplus ones removed.
+func Marshal(f *File, v interface{}) error {
+	if m, ok := v.(Marshaler); ok {
+		return m.MarshalCore(f)
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		name, ok := fieldName(rt.Field(i))
+		if !ok {
+			continue
+		}
+		fv := rv.Field(i)
+		switch {
+		case fv.Kind() == reflect.Struct:
+			s := f.Section(name)
+			if s == nil {
+				if fv.IsZero() {
+					continue
+				}
+				s = f.AddSection(name)
+			}
+			if err := encodeSection(s, fv); err != nil {
+				return err
+			}
+		case fv.Kind() == reflect.Slice && fv.Type().Elem().Kind() == reflect.Struct:
+			sections := f.AllSections(name)
+			for j := 0; j < fv.Len(); j++ {
+				var s *Section
+				if j < len(sections) {
+					s = sections[j]
+				} else {
+					s = f.insertSectionAfter(sections, name)
+					sections = append(sections, s)
+				}
+				if err := encodeSection(s, fv.Index(j)); err != nil {
+					return err
+				}
+			}
+			for _, s := range sections[fv.Len():] {
+				f.RemoveSection(s)
+			}
+		default:
+			return fmt.Errorf("%w: section %s has type %s", ErrUnsupportedType, name, fv.Type())
+		}
+	}
+	return nil
+}
+
+// insertSectionAfter adds a section after the last of the given sections.
+func (f *File) insertSectionAfter(sections []*Section, name string) *Section {
+	s := &Section{Name: name}
+	if len(sections) == 0 {
+		f.Sections = append(f.Sections, s)
+		return s
+	}
+	last := sections[len(sections)-1]
+	for i := range f.Sections {
+		if f.Sections[i] == last {
+			f.Sections = append(f.Sections, nil)
+			copy(f.Sections[i+2:], f.Sections[i+1:])
+			f.Sections[i+1] = s
+			break
+		}
+	}
+	return s
+}
+
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		// unexported
+		return "", false
+	}
+	tag := field.Tag.Get("ini")
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	}
+	return tag, true
+}
+
+func decodeSection(s *Section, v reflect.Value) error {
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: section %s has type %s", ErrUnsupportedType, s.Name, v.Type())
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		value, ok := s.Get(key)
+		if !ok {
+			continue
+		}
+		if err := decodeValue(value, v.Field(i)); err != nil {
+			return fmt.Errorf("[%s] %s: %w", s.Name, key, err)
+		}
+	}
+	return nil
+}
+
+func encodeSection(s *Section, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		fv := v.Field(i)
+		old, exists := s.Get(key)
+		if !exists && fv.IsZero() {
+			continue
+		}
+		value, err := encodeValue(fv, old)
+		if err != nil {
+			return fmt.Errorf("[%s] %s: %w", s.Name, key, err)
+		}
+		s.Set(key, value)
+	}
+	return nil
+}
+
+func decodeValue(s string, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(unquote(s))
+	case reflect.Bool:
+		// The engine stores flags as integers, any non-zero value is set.
+		switch s {
+		case "true":
+			v.SetBool(true)
+		case "false", "":
+			v.SetBool(false)
+		default:
+			i, err := strconv.ParseInt(s, 10, 32)
+			if err != nil {
+				return fmt.Errorf("%w: %q", ErrInvalidValue, s)
+			}
+			v.SetBool(i != 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidValue, s)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%w: %q", ErrInvalidValue, s)
+		}
+		v.SetUint(i)
+	case reflect.Slice:
+		var parts []string
+		if s != "" {
+			parts = strings.Split(s, ",")
+		}
+		slice := reflect.MakeSlice(v.Type(), len(parts), len(parts))
+		for i, p := range parts {
+			elem := slice.Index(i)
+			if elem.Kind() == reflect.Slice || elem.Kind() == reflect.Struct {
+				return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+			}
+			if err := decodeValue(strings.TrimSpace(p), elem); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	return nil
+}
+
+// encodeValue formats v. The old value determines the formatting of
+// booleans and strings.
+func encodeValue(v reflect.Value, old string) (string, error) {
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if isQuoted(old) || strings.TrimSpace(s) != s {
+			return quote(s), nil
+		}
+		return s, nil
+	case reflect.Bool:
+		if i, err := strconv.ParseInt(old, 10, 32); err == nil {
+			// Keep integer flags like Exclusive=2 if they didn't change.
+			if (i != 0) == v.Bool() {
+				return old, nil
+			}
+			if v.Bool() {
+				return "1", nil
+			}
+			return "0", nil
+		}
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			elem := v.Index(i)
+			if elem.Kind() == reflect.Slice || elem.Kind() == reflect.Struct {
+				return "", fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+			}
+			p, err := encodeValue(elem, "")
+			if err != nil {
+				return "", err
+			}
+			parts[i] = p
+		}
+		return strings.Join(parts, ","), nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"'
+}
+
+// unquote removes quotes around s. Within quotes, backslash escapes the
+// next character like in the engine.
+func unquote(s string) string {
+	if !isQuoted(s) {
+		return s
+	}
+	s = s[1 : len(s)-1]
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// reflectValue returns the struct v points to.
+func reflectValue(v interface{}) reflect.Value {
+	return reflect.ValueOf(v).Elem()
+}
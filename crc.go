@@ -0,0 +1,131 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+var ErrCRCMismatch error = errors.New("c4group: entry data does not match CRC")
+
+// FileCRC calculates the checksum of a file entry like the engine does
+// (C4Group::CalcCRC32): the CRC32 of the file contents followed by the
+// filename. Empty files have a checksum of 0.
+func FileCRC(filename string, r io.Reader) (uint32, error) {
+	crc := crc32.NewIEEE()
+	n, err := io.Copy(crc, r)
+	if err != nil {
+		return 0, err
+	}
+	return fileCRC(crc.Sum32(), n, filename, crcNew), nil
+}
+
+// fileCRC finishes a file checksum from the CRC32 of the file contents.
+func fileCRC(crc uint32, size int64, filename string, kind byte) uint32 {
+	if size == 0 {
+		return 0
+	}
+	if kind == crcOld {
+		return crc
+	}
+	return crc32.Update(crc, crc32.IEEETable, []byte(filename))
+}
+
+// ContentsCRC calculates the checksum of the remaining entries in r like the
+// engine does (C4Group::EntryCRC32). The engine uses it to check whether two
+// groups are the same resource, e.g. in network games.
+//
+// The checksum of a group is the XOR of the checksums of all its entries,
+// descending into child groups. Unlike a checksum of the whole file, it does
+// not depend on compression or entry order.
+func ContentsCRC(r *Reader) (uint32, error) {
+	var crc uint32
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			return crc, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var c uint32
+		if entry.IsGroup {
+			sub, err := r.ReadGroup()
+			if err != nil {
+				return 0, err
+			}
+			c, err = ContentsCRC(sub)
+		} else {
+			c, err = FileCRC(entry.Filename, r)
+		}
+		if err != nil {
+			return 0, err
+		}
+		crc ^= c
+	}
+}
+
+// GroupContentsCRC calculates the checksum of all entries in g like
+// ContentsCRC.
+func GroupContentsCRC(g *Group) (uint32, error) {
+	t, err := groupCRCs(g)
+	if err != nil {
+		return 0, err
+	}
+	return t.sum(), nil
+}
+
+// ComputeCRC sets the checksums of all entries recursively. This reads all
+// file data once.
+func (b *Builder) ComputeCRC() error {
+	for _, e := range b.entries {
+		crc, err := e.computeCRC()
+		if err != nil {
+			return err
+		}
+		e.CRC = crc
+		e.HasCRC = true
+	}
+	return nil
+}
+
+func (e *builderEntry) computeCRC() (uint32, error) {
+	if e.group != nil {
+		if err := e.group.ComputeCRC(); err != nil {
+			return 0, err
+		}
+		var crc uint32
+		for _, sub := range e.group.entries {
+			crc ^= sub.CRC
+		}
+		return crc, nil
+	}
+	r, err := e.open()
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+	if e.IsGroup {
+		// Raw child group data
+		sub := &Reader{r: r}
+		if err := sub.init(); err != nil {
+			return 0, err
+		}
+		return ContentsCRC(sub)
+	}
+	return FileCRC(e.Filename, r)
+}
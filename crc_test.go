@@ -0,0 +1,181 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// stringFile returns an open function for Builder.AddFile.
+func stringFile(s string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(s)), nil
+	}
+}
+
+func TestFileCRC(t *testing.T) {
+	crc, err := FileCRC("Script.c", strings.NewReader("#strict 2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := crc32.Update(crc32.ChecksumIEEE([]byte("#strict 2")), crc32.IEEETable, []byte("Script.c"))
+	if crc != want {
+		t.Errorf("got %x, want %x", crc, want)
+	}
+	if crc, _ := FileCRC("Empty.txt", strings.NewReader("")); crc != 0 {
+		t.Errorf("empty file: got %x, want 0", crc)
+	}
+}
+
+func TestBuilderCRC(t *testing.T) {
+	b := NewBuilder("Test.ocs")
+	b.AddFile(Entry{Filename: "Script.c", Size: 9}, stringFile("#strict 2"))
+	sub := b.AddGroup(Entry{Filename: "Objects.ocd"})
+	sub.AddFile(Entry{Filename: "DefCore.txt", Size: 9}, stringFile("[DefCore]"))
+	sub.AddFile(Entry{Filename: "Names.txt", Size: 0}, stringFile(""))
+	if err := b.ComputeCRC(); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := b.Pack(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.VerifyCRC = true
+	defCoreCRC, _ := FileCRC("DefCore.txt", strings.NewReader("[DefCore]"))
+	if !r.Entries[1].HasCRC || r.Entries[1].CRC != defCoreCRC {
+		t.Errorf("Objects.ocd: got CRC %x, want %x", r.Entries[1].CRC, defCoreCRC)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Errorf("Script.c: %v", err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	objects, err := r.ReadGroup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := objects.Next(); err != nil {
+			break
+		}
+		if _, err := ioutil.ReadAll(objects); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestVerifyCRC(t *testing.T) {
+	var buf bytes.Buffer
+	cw := NewWriter(&buf)
+	cw.WriteHeader(&Header{Entries: 1})
+	cw.WriteEntry(&Entry{Filename: "Script.c", Size: 9, HasCRC: true, CRC: 1234})
+	io.WriteString(cw, "#strict 2")
+	cw.Close()
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Next()
+	if _, err := ioutil.ReadAll(r); err != nil {
+		t.Errorf("expected no error without VerifyCRC, got %v", err)
+	}
+
+	r, _ = NewReader(bytes.NewReader(buf.Bytes()))
+	r.VerifyCRC = true
+	r.Next()
+	if _, err := ioutil.ReadAll(r); err != ErrCRCMismatch {
+		t.Errorf("expected ErrCRCMismatch, got %v", err)
+	}
+}
+
+func TestContentsCRC(t *testing.T) {
+	pack := func(names ...string) []byte {
+		b := NewBuilder("Test.ocd")
+		for _, name := range names {
+			if name == "Sub.ocd" {
+				sub := b.AddGroup(Entry{Filename: name})
+				sub.AddFile(Entry{Filename: "DefCore.txt", Size: 9}, stringFile("[DefCore]"))
+				continue
+			}
+			b.AddFile(Entry{Filename: name, Size: len(name)}, stringFile(name))
+		}
+		var buf bytes.Buffer
+		if err := b.Pack(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	contentsCRC := func(data []byte) uint32 {
+		r, err := NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		crc, err := ContentsCRC(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return crc
+	}
+	crc1 := contentsCRC(pack("Script.c", "Sub.ocd", "Graphics.png"))
+	crc2 := contentsCRC(pack("Sub.ocd", "Graphics.png", "Script.c"))
+	if crc1 != crc2 {
+		t.Errorf("checksum depends on order: %x != %x", crc1, crc2)
+	}
+	crc3 := contentsCRC(pack("Script.c", "Graphics.png"))
+	if crc1 == crc3 {
+		t.Error("checksum does not include child group")
+	}
+
+	// The checksum matches the XOR of the checksums written by the Builder.
+	b := NewBuilder("Test.ocd")
+	sub := b.AddGroup(Entry{Filename: "Sub.ocd"})
+	sub.AddFile(Entry{Filename: "DefCore.txt", Size: 9}, stringFile("[DefCore]"))
+	b.AddFile(Entry{Filename: "Script.c", Size: 8}, stringFile("Script.c"))
+	b.AddFile(Entry{Filename: "Graphics.png", Size: 12}, stringFile("Graphics.png"))
+	if err := b.ComputeCRC(); err != nil {
+		t.Fatal(err)
+	}
+	var crc uint32
+	for _, e := range b.entries {
+		crc ^= e.CRC
+	}
+	if crc != crc1 {
+		t.Errorf("got %x from Builder, want %x", crc, crc1)
+	}
+
+	data := pack("Script.c", "Sub.ocd", "Graphics.png")
+	g, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crc, err := GroupContentsCRC(g); err != nil || crc != crc1 {
+		t.Errorf("GroupContentsCRC: got %x, %v, want %x", crc, err, crc1)
+	}
+}
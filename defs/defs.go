@@ -0,0 +1,214 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package defs enumerates the object definitions in a group.
+//
+// Groups are accessed through io/fs, so both packed groups and unpacked
+// directories (see c4group.Open and c4group.NewFS) can be indexed. With
+// packed groups, only the required entries are decompressed.
+package defs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/lluchs/c4group-go"
+	"github.com/lluchs/c4group-go/core"
+)
+
+var ErrNoID error = errors.New("defs: DefCore.txt has no id")
+
+// Def describes a single definition.
+type Def struct {
+	ID       string
+	Name     string // localized name, empty if not found
+	Category string // C4D_* flags separated by '|'
+	Version  []int
+	Path     string // slash-separated path of the definition group
+
+	HasGraphics bool // Graphics.png
+	HasMesh     bool // Graphics.mesh
+}
+
+// Duplicate lists definitions sharing the same ID.
+type Duplicate struct {
+	ID    string
+	Paths []string
+}
+
+// Catalog is the result of Index.
+type Catalog struct {
+	Defs       []Def // in file system order
+	Duplicates []Duplicate
+	// Errors contains definitions whose DefCore.txt couldn't be parsed or
+	// lacks an ID.
+	Errors []error
+}
+
+// IndexOptions configures Index.
+type IndexOptions struct {
+	// Languages in order of preference for resolving definition names.
+	// Defaults to US, then DE.
+	Languages []string
+}
+
+// Index finds all definitions (groups matching C4CFN_DefFiles with a
+// DefCore.txt) in fsys recursively. Definitions may be nested. opts may be
+// nil.
+func Index(fsys fs.FS, opts *IndexOptions) (*Catalog, error) {
+	if opts == nil {
+		opts = &IndexOptions{}
+	}
+	langs := opts.Languages
+	if langs == nil {
+		langs = []string{"US", "DE"}
+	}
+	cat := &Catalog{}
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if p != "." && !c4group.WildcardListMatch(c4group.C4CFN_DefFiles, d.Name()) {
+			return nil
+		}
+		def, err := readDef(fsys, p, langs)
+		if errors.Is(err, fs.ErrNotExist) {
+			// not a definition, e.g. Objects.ocd
+			return nil
+		}
+		if errors.Is(err, core.ErrInvalidValue) || errors.Is(err, ErrNoID) {
+			cat.Errors = append(cat.Errors, fmt.Errorf("%s: %w", p, err))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cat.Defs = append(cat.Defs, *def)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	cat.Duplicates = findDuplicates(cat.Defs)
+	return cat, nil
+}
+
+// readDef reads the definition in the directory dir. It returns an error
+// wrapping fs.ErrNotExist if there's no DefCore.txt.
+func readDef(fsys fs.FS, dir string, langs []string) (*Def, error) {
+	data, err := fs.ReadFile(fsys, path.Join(dir, c4group.C4CFN_DefCore))
+	if err != nil {
+		return nil, err
+	}
+	// Only decode the values we need so that other invalid values don't
+	// matter.
+	var defCore struct {
+		DefCore struct {
+			ID       string `ini:"id"`
+			Category string
+			Version  []int
+		}
+	}
+	if _, err := core.Decode(bytes.NewReader(data), &defCore); err != nil {
+		return nil, err
+	}
+	if defCore.DefCore.ID == "" {
+		return nil, ErrNoID
+	}
+	def := &Def{
+		ID:          defCore.DefCore.ID,
+		Category:    defCore.DefCore.Category,
+		Version:     defCore.DefCore.Version,
+		Path:        dir,
+		HasGraphics: exists(fsys, path.Join(dir, c4group.C4CFN_DefGraphics)),
+		HasMesh:     exists(fsys, path.Join(dir, c4group.C4CFN_DefMesh)),
+	}
+	if def.Name, err = readName(fsys, dir, langs); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+func exists(fsys fs.FS, name string) bool {
+	_, err := fs.Stat(fsys, name)
+	return err == nil
+}
+
+var nameRegexp = regexp.MustCompile(`(?m)^\s*local\s+Name\s*=\s*("(?:[^"\\]|\\.)*")`)
+
+// readName resolves the definition's name from Names.txt or the script's
+// Name property.
+func readName(fsys fs.FS, dir string, langs []string) (string, error) {
+	data, err := fs.ReadFile(fsys, path.Join(dir, c4group.C4CFN_Names))
+	if err == nil {
+		_, name := core.LanguageString(string(data), langs)
+		return name, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	script, err := fs.ReadFile(fsys, path.Join(dir, "Script.c"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	m := nameRegexp.FindSubmatch(script)
+	if m == nil {
+		return "", nil
+	}
+	name, err := strconv.Unquote(string(m[1]))
+	if err != nil {
+		return "", nil
+	}
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		return "", err
+	}
+	l, err := core.Localize(sub, langs)
+	if err != nil {
+		return "", err
+	}
+	return core.ReplaceStrings(name, l.Strings), nil
+}
+
+func findDuplicates(defs []Def) []Duplicate {
+	paths := make(map[string][]string)
+	var ids []string
+	for _, def := range defs {
+		if def.ID == "" {
+			continue
+		}
+		if _, ok := paths[def.ID]; !ok {
+			ids = append(ids, def.ID)
+		}
+		paths[def.ID] = append(paths[def.ID], def.Path)
+	}
+	sort.Strings(ids)
+	var dups []Duplicate
+	for _, id := range ids {
+		if len(paths[id]) > 1 {
+			dups = append(dups, Duplicate{ID: id, Paths: paths[id]})
+		}
+	}
+	return dups
+}
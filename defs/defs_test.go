@@ -0,0 +1,59 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package defs
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIndex(t *testing.T) {
+	fsys := fstest.MapFS{
+		"Objects.ocd/Clonk.ocd/DefCore.txt":          {Data: []byte("[DefCore]\r\nid=Clonk\r\nVersion=8,0\r\nCategory=C4D_Living\r\n")},
+		"Objects.ocd/Clonk.ocd/Graphics.mesh":        {Data: []byte("mesh")},
+		"Objects.ocd/Clonk.ocd/Script.c":             {Data: []byte("#include Library_Clonk\n\nlocal Name = \"$Name$\";\n")},
+		"Objects.ocd/Clonk.ocd/StringTblDE.txt":      {Data: []byte("Name=Clonk (DE)\n")},
+		"Objects.ocd/Clonk.ocd/StringTblUS.txt":      {Data: []byte("Name=Clonk\n")},
+		"Objects.ocd/Rock.ocd/DefCore.txt":           {Data: []byte("[DefCore]\nid=Rock\nCategory=C4D_Object\n")},
+		"Objects.ocd/Rock.ocd/Graphics.png":          {Data: []byte("png")},
+		"Objects.ocd/Rock.ocd/Names.txt":             {Data: []byte("DE:Stein\nUS:Rock\n")},
+		"Objects.ocd/Rock.ocd/Small.ocd/DefCore.txt": {Data: []byte("[DefCore]\nid=SmallRock\n")},
+		"Objects.ocd/Broken.ocd/DefCore.txt":         {Data: []byte("[DefCore]\nid=Broken\nVersion=x\n")},
+		"Other.ocd/Rock.ocd/DefCore.txt":             {Data: []byte("[DefCore]\nid=Rock\n")},
+		"Material.ocg/Earth.ocm":                     {Data: []byte("")},
+		"Misc/NoID.ocd/DefCore.txt":                  {Data: []byte("[DefCore]\nCategory=C4D_Object\n")},
+	}
+	cat, err := Index(fsys, &IndexOptions{Languages: []string{"DE"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Def{
+		{ID: "Clonk", Name: "Clonk (DE)", Category: "C4D_Living", Version: []int{8, 0}, Path: "Objects.ocd/Clonk.ocd", HasMesh: true},
+		{ID: "Rock", Name: "Stein", Category: "C4D_Object", Path: "Objects.ocd/Rock.ocd", HasGraphics: true},
+		{ID: "SmallRock", Path: "Objects.ocd/Rock.ocd/Small.ocd"},
+		{ID: "Rock", Path: "Other.ocd/Rock.ocd"},
+	}
+	if !reflect.DeepEqual(cat.Defs, want) {
+		t.Errorf("Defs:\ngot  %+v\nwant %+v", cat.Defs, want)
+	}
+	wantDups := []Duplicate{{ID: "Rock", Paths: []string{"Objects.ocd/Rock.ocd", "Other.ocd/Rock.ocd"}}}
+	if !reflect.DeepEqual(cat.Duplicates, wantDups) {
+		t.Errorf("Duplicates: got %+v, want %+v", cat.Duplicates, wantDups)
+	}
+	if len(cat.Errors) != 2 {
+		t.Errorf("Errors: got %v, want errors for Misc/NoID.ocd and Broken.ocd", cat.Errors)
+	}
+}
@@ -0,0 +1,450 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package defs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/lluchs/c4group-go"
+	"github.com/lluchs/c4group-go/core"
+)
+
+// NodeKind distinguishes the nodes of a dependency graph.
+type NodeKind int
+
+const (
+	DefNode      NodeKind = iota // a definition, named by its ID
+	ScenarioNode                 // a scenario, named by its path
+	PackNode                     // a definition pack like Objects.ocd, named by its path
+)
+
+var nodeKindNames = []string{"def", "scenario", "pack"}
+
+func (k NodeKind) String() string { return nodeKindNames[k] }
+
+// MarshalText implements encoding.TextMarshaler.
+func (k NodeKind) MarshalText() ([]byte, error) { return []byte(k.String()), nil }
+
+// EdgeKind describes why a node depends on another.
+type EdgeKind int
+
+const (
+	Include     EdgeKind = iota // #include in a script
+	AppendTo                    // #appendto in a script
+	Reference                   // an ID used in a script or Scenario.txt
+	Definitions                 // DefinitionN in Scenario.txt or a pack containing a referenced definition
+)
+
+var edgeKindNames = []string{"include", "appendto", "reference", "definitions"}
+
+func (k EdgeKind) String() string { return edgeKindNames[k] }
+
+// MarshalText implements encoding.TextMarshaler.
+func (k EdgeKind) MarshalText() ([]byte, error) { return []byte(k.String()), nil }
+
+// Node is a definition, scenario or definition pack.
+type Node struct {
+	Kind NodeKind `json:"kind"`
+	Name string   `json:"name"`
+}
+
+// Edge means that From depends on To.
+type Edge struct {
+	From Node     `json:"from"`
+	To   Node     `json:"to"`
+	Kind EdgeKind `json:"kind"`
+}
+
+// Graph is the dependency graph returned by Dependencies.
+type Graph struct {
+	Nodes []Node `json:"nodes"` // sorted by kind and name
+	Edges []Edge `json:"edges"` // sorted by From, To and Kind
+	// Missing lists IDs which are included or appended to, but which
+	// aren't in the catalog.
+	Missing []string `json:"missing"`
+
+	missingEdges []Edge // edges to Missing IDs, not part of Edges
+}
+
+// Dependencies builds the dependency graph of the definitions and scenarios
+// in fsys. cat must be the result of Index on the same fsys and determines
+// which identifiers are definition IDs.
+//
+// Scripts (C4CFN_Script) of definitions and scenarios are scanned for
+// #include, #appendto and identifiers matching a definition ID. Scenarios
+// additionally depend on the IDs in Scenario.txt, Objects.txt and
+// Objects.c and on the definition packs listed in their DefinitionN values
+// or containing a definition they reference.
+func Dependencies(fsys fs.FS, cat *Catalog) (*Graph, error) {
+	b := &graphBuilder{
+		ids:          make(map[string]bool),
+		nodes:        make(map[Node]bool),
+		edges:        make(map[Edge]bool),
+		missing:      make(map[string]bool),
+		missingEdges: make(map[Edge]bool),
+		packs:        make(map[string]string),
+	}
+	for _, def := range cat.Defs {
+		b.ids[def.ID] = true
+		b.nodes[Node{DefNode, def.ID}] = true
+	}
+	defPaths := make(map[string]bool)
+	for _, def := range cat.Defs {
+		defPaths[def.Path] = true
+	}
+	for _, def := range cat.Defs {
+		if pack := packOf(def.Path, defPaths); pack != "" {
+			if _, ok := b.packs[def.ID]; !ok {
+				b.packs[def.ID] = pack
+			}
+		}
+	}
+
+	for _, def := range cat.Defs {
+		node := Node{DefNode, def.ID}
+		scripts, err := scriptFiles(fsys, def.Path, isScript)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.scanScripts(fsys, node, scripts); err != nil {
+			return nil, err
+		}
+	}
+
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if defPaths[p] {
+			// Definitions can't contain scenarios.
+			return fs.SkipDir
+		}
+		data, err := fs.ReadFile(fsys, path.Join(p, c4group.C4CFN_ScenarioCore))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return b.scanScenario(fsys, p, data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.graph(), nil
+}
+
+type graphBuilder struct {
+	ids          map[string]bool
+	nodes        map[Node]bool
+	edges        map[Edge]bool
+	missing      map[string]bool
+	missingEdges map[Edge]bool
+	packs        map[string]string // definition ID -> pack path
+}
+
+func (b *graphBuilder) addEdge(from, to Node, kind EdgeKind) {
+	if from == to {
+		return
+	}
+	b.nodes[to] = true
+	b.edges[Edge{from, to, kind}] = true
+}
+
+// addDef adds an edge to a definition. For scenarios, an edge to the
+// definition's pack is added as well.
+func (b *graphBuilder) addDef(from Node, id string, kind EdgeKind) {
+	if !b.ids[id] {
+		if kind != Reference {
+			b.missing[id] = true
+			b.missingEdges[Edge{from, Node{DefNode, id}, kind}] = true
+		}
+		return
+	}
+	b.addEdge(from, Node{DefNode, id}, kind)
+	if from.Kind != ScenarioNode || from.Name == "." {
+		return
+	}
+	// Packs inside the scenario are part of it.
+	if pack, ok := b.packs[id]; ok && !strings.HasPrefix(pack, from.Name+"/") {
+		b.addEdge(from, Node{PackNode, pack}, Definitions)
+	}
+}
+
+var directiveRegexp = regexp.MustCompile(`(?m)^[ \t]*#(include|appendto)[ \t]+(\*|[A-Za-z_][A-Za-z0-9_]*)[^\n]*$`)
+var identRegexp = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+func (b *graphBuilder) scanScripts(fsys fs.FS, node Node, files []string) error {
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return err
+		}
+		src := stripScript(data)
+		for _, m := range directiveRegexp.FindAllSubmatch(src, -1) {
+			if string(m[2]) == "*" {
+				continue
+			}
+			kind := Include
+			if string(m[1]) == "appendto" {
+				kind = AppendTo
+			}
+			b.addDef(node, string(m[2]), kind)
+		}
+		src = directiveRegexp.ReplaceAll(src, nil)
+		for _, id := range identRegexp.FindAll(src, -1) {
+			b.addDef(node, string(id), Reference)
+		}
+	}
+	return nil
+}
+
+// scenarioKeys are the Scenario.txt values containing definition IDs.
+var scenarioKeys = map[string][]string{
+	"Game":        {"Goals", "Rules"},
+	"Player1":     {"Crew", "Buildings", "Vehicles", "Material", "Knowledge", "HomeBaseMaterial", "HomeBaseProduction", "Magic"},
+	"Landscape":   {"Vegetation", "InEarth"},
+	"Animals":     {"Animal", "Nest"},
+	"Environment": {"Objects"},
+}
+
+func (b *graphBuilder) scanScenario(fsys fs.FS, dir string, data []byte) error {
+	node := Node{ScenarioNode, dir}
+	b.nodes[node] = true
+	f, err := core.Parse(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path.Join(dir, c4group.C4CFN_ScenarioCore), err)
+	}
+	for _, s := range f.Sections {
+		name := s.Name
+		if strings.HasPrefix(name, "Player") {
+			name = "Player1"
+		}
+		for _, key := range scenarioKeys[name] {
+			value, _ := s.Get(key)
+			for _, id := range identRegexp.FindAllString(value, -1) {
+				b.addDef(node, id, Reference)
+			}
+		}
+	}
+	// Objects.txt of old scenarios lists objects in [Object] sections.
+	objects, err := fs.ReadFile(fsys, path.Join(dir, c4group.C4CFN_ScenarioObjects))
+	if err == nil {
+		f, err := core.Parse(bytes.NewReader(objects))
+		if err != nil {
+			return fmt.Errorf("%s: %w", path.Join(dir, c4group.C4CFN_ScenarioObjects), err)
+		}
+		for _, s := range f.Sections {
+			if id, ok := s.Get("id"); ok {
+				b.addDef(node, id, Reference)
+			}
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	var scen struct{ Definitions core.ScenarioDefinitions }
+	if err := core.Unmarshal(f, &scen); err != nil {
+		return fmt.Errorf("%s: %w", path.Join(dir, c4group.C4CFN_ScenarioCore), err)
+	}
+	for _, pack := range scen.Definitions.List() {
+		b.addEdge(node, Node{PackNode, strings.ReplaceAll(pack, "\\", "/")}, Definitions)
+	}
+
+	scripts, err := scriptFiles(fsys, dir, func(name string) bool {
+		return isScript(name) || name == c4group.C4CFN_ScenarioObjectsScript
+	})
+	if err != nil {
+		return err
+	}
+	system, err := scriptFiles(fsys, path.Join(dir, c4group.C4CFN_System), func(name string) bool {
+		return strings.HasSuffix(name, ".c")
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return b.scanScripts(fsys, node, append(scripts, system...))
+}
+
+func (b *graphBuilder) graph() *Graph {
+	g := &Graph{}
+	for n := range b.nodes {
+		g.Nodes = append(g.Nodes, n)
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return nodeLess(g.Nodes[i], g.Nodes[j]) })
+	for e := range b.edges {
+		g.Edges = append(g.Edges, e)
+	}
+	sort.Slice(g.Edges, func(i, j int) bool {
+		a, b := g.Edges[i], g.Edges[j]
+		if a.From != b.From {
+			return nodeLess(a.From, b.From)
+		}
+		if a.To != b.To {
+			return nodeLess(a.To, b.To)
+		}
+		return a.Kind < b.Kind
+	})
+	for e := range b.missingEdges {
+		g.missingEdges = append(g.missingEdges, e)
+	}
+	for id := range b.missing {
+		g.Missing = append(g.Missing, id)
+	}
+	sort.Strings(g.Missing)
+	return g
+}
+
+func nodeLess(a, b Node) bool {
+	if a.Kind != b.Kind {
+		return a.Kind < b.Kind
+	}
+	return a.Name < b.Name
+}
+
+// Needs returns the IDs of all definitions node depends on, directly or
+// indirectly, in sorted order.
+func (g *Graph) Needs(node Node) []string {
+	var ids []string
+	for n := range g.reachable(node) {
+		if n.Kind == DefNode && n != node {
+			ids = append(ids, n.Name)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// missingNeeds returns the Missing IDs which node or the definitions it
+// needs include or append to, in sorted order.
+func (g *Graph) missingNeeds(node Node) []string {
+	reachable := g.reachable(node)
+	seen := make(map[string]bool)
+	var ids []string
+	for _, e := range g.missingEdges {
+		if reachable[e.From] && !seen[e.To.Name] {
+			seen[e.To.Name] = true
+			ids = append(ids, e.To.Name)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// reachable returns the nodes reachable from node, including node itself.
+func (g *Graph) reachable(node Node) map[Node]bool {
+	out := make(map[Node][]Node)
+	for _, e := range g.Edges {
+		out[e.From] = append(out[e.From], e.To)
+	}
+	seen := map[Node]bool{node: true}
+	queue := []Node{node}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		for _, to := range out[n] {
+			if !seen[to] {
+				seen[to] = true
+				queue = append(queue, to)
+			}
+		}
+	}
+	return seen
+}
+
+// isScript matches C4CFN_Script with any language.
+func isScript(name string) bool {
+	return c4group.WildcardListMatch(strings.ReplaceAll(c4group.C4CFN_Script, "%s", "*"), name)
+}
+
+// scriptFiles lists the files in dir accepted by match.
+func scriptFiles(fsys fs.FS, dir string, match func(name string) bool) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && match(e.Name()) {
+			files = append(files, path.Join(dir, e.Name()))
+		}
+	}
+	return files, nil
+}
+
+// packOf returns the outermost .ocd group containing the definition at p
+// which isn't a definition itself, e.g. Objects.ocd.
+func packOf(p string, defPaths map[string]bool) string {
+	parts := strings.Split(p, "/")
+	for i := 1; i < len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if c4group.WildcardListMatch(c4group.C4CFN_DefFiles, parts[i-1]) && !defPaths[dir] {
+			return dir
+		}
+	}
+	return ""
+}
+
+// stripScript replaces comments and string literals in a C4Script source
+// with spaces, keeping line breaks.
+func stripScript(src []byte) []byte {
+	out := make([]byte, len(src))
+	copy(out, src)
+	blank := func(i int) {
+		if out[i] != '\n' {
+			out[i] = ' '
+		}
+	}
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			for ; i < len(out) && out[i] != '\n'; i++ {
+				blank(i)
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			start := i
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out)
+			} else {
+				end += i + 4
+			}
+			for i = start; i < end; i++ {
+				blank(i)
+			}
+			i--
+		case out[i] == '"':
+			blank(i)
+			for i++; i < len(out) && out[i] != '"' && out[i] != '\n'; i++ {
+				if out[i] == '\\' && i+1 < len(out) {
+					blank(i)
+					i++
+				}
+				blank(i)
+			}
+			if i < len(out) {
+				blank(i)
+			}
+		}
+	}
+	return out
+}
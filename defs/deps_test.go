@@ -0,0 +1,75 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package defs
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestDependencies(t *testing.T) {
+	defCore := func(id string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte("[DefCore]\nid=" + id + "\n")}
+	}
+	script := func(s string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(s)}
+	}
+	fsys := fstest.MapFS{
+		"Objects.ocd/Libraries.ocd/Library_Clonk.ocd/DefCore.txt": defCore("Library_Clonk"),
+		"Objects.ocd/Clonk.ocd/DefCore.txt":                       defCore("Clonk"),
+		"Objects.ocd/Clonk.ocd/Script.c":                          script("#include Library_Clonk\n#include Library_Missing\n\nfunc Initialize() {\n\t// Rock\n\tCreateContents(Shovel); /* Rock */\n\tMessage(\"Rock\");\n}\n"),
+		"Objects.ocd/Shovel.ocd/DefCore.txt":                      defCore("Shovel"),
+		"Objects.ocd/Rock.ocd/DefCore.txt":                        defCore("Rock"),
+		"Objects.ocd/Rock.ocd/ScriptDE.c":                         script("#appendto *\n"),
+		"Objects.ocd/Rock.ocd/Unused.ocd/DefCore.txt":             defCore("Unused"),
+		"Arena.ocs/Scenario.txt":                                  script("[Definitions]\nDefinition1=Objects.ocd\n\n[Player1]\nCrew=Clonk=2\n"),
+		"Arena.ocs/Objects.c":                                     script("func InitializeObjects() {\n\tCreateObject(Rock, 10, 10);\n\tCreateObject(Local);\n}\n"),
+		"Arena.ocs/System.ocg/Rules.c":                            script("#appendto Clonk\n"),
+		"Arena.ocs/Local.ocd/DefCore.txt":                         defCore("Local"),
+	}
+	cat, err := Index(fsys, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Dependencies(fsys, cat)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	def := func(id string) Node { return Node{DefNode, id} }
+	scen := Node{ScenarioNode, "Arena.ocs"}
+	pack := Node{PackNode, "Objects.ocd"}
+	want := []Edge{
+		{def("Clonk"), def("Library_Clonk"), Include},
+		{def("Clonk"), def("Shovel"), Reference},
+		{scen, def("Clonk"), AppendTo},
+		{scen, def("Clonk"), Reference},
+		{scen, def("Local"), Reference},
+		{scen, def("Rock"), Reference},
+		{scen, pack, Definitions},
+	}
+	if !reflect.DeepEqual(g.Edges, want) {
+		t.Errorf("Edges:\ngot  %v\nwant %v", g.Edges, want)
+	}
+	if !reflect.DeepEqual(g.Missing, []string{"Library_Missing"}) {
+		t.Errorf("Missing: got %v", g.Missing)
+	}
+	needs := g.Needs(scen)
+	wantNeeds := []string{"Clonk", "Library_Clonk", "Local", "Rock", "Shovel"}
+	if !reflect.DeepEqual(needs, wantNeeds) {
+		t.Errorf("Needs: got %v, want %v", needs, wantNeeds)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+package defs
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/lluchs/c4group-go"
+	"github.com/lluchs/c4group-go/core"
+)
+
+// Pack is a definition group available to scenarios, e.g. the system
+// Objects.ocd.
+type Pack struct {
+	Name string // filename as used in Scenario.txt, e.g. "Objects.ocd"
+	FS   fs.FS
+}
+
+// Resolution lists the definitions a scenario needs from packs.
+type Resolution struct {
+	// Defs are the needed definitions which aren't part of the scenario.
+	// Their Path starts with the pack name. Definitions inside another
+	// needed definition are omitted as they come with their parent.
+	Defs []Def
+	// Missing lists needed IDs which neither the scenario nor the packs
+	// define.
+	Missing []string
+	// Errors contains invalid definitions, see Catalog.
+	Errors []error
+}
+
+// Resolve determines the definitions from packs which the scenario needs,
+// see Dependencies. name is the scenario's filename, e.g. "Arena.ocs". If
+// Scenario.txt lists definitions in its [Definitions] section, only the
+// packs listed there are used. Otherwise, all packs are used, with earlier
+// packs taking precedence for duplicate IDs.
+func Resolve(name string, scenario fs.FS, packs []Pack) (*Resolution, error) {
+	mount, err := mountScenario(name, scenario, packs)
+	if err != nil {
+		return nil, err
+	}
+	return resolve(name, mount, packs)
+}
+
+func resolve(name string, mount mountFS, packs []Pack) (*Resolution, error) {
+	cat, err := Index(mount, nil)
+	if err != nil {
+		return nil, err
+	}
+	graph, err := Dependencies(mount, cat)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Resolution{Errors: cat.Errors}
+	local := make(map[string]bool)
+	for _, def := range cat.Defs {
+		if strings.HasPrefix(def.Path, name+"/") {
+			local[def.ID] = true
+		}
+	}
+	for _, id := range graph.Needs(Node{ScenarioNode, name}) {
+		if local[id] {
+			continue
+		}
+		if def, ok := findDef(cat, packs, id); ok {
+			res.Defs = append(res.Defs, def)
+		} else {
+			res.Missing = append(res.Missing, id)
+		}
+	}
+	res.Missing = append(res.Missing, graph.missingNeeds(Node{ScenarioNode, name})...)
+	sort.Strings(res.Missing)
+
+	// Drop definitions contained in others.
+	defs := res.Defs[:0]
+outer:
+	for _, def := range res.Defs {
+		for _, parent := range res.Defs {
+			if strings.HasPrefix(def.Path, parent.Path+"/") {
+				continue outer
+			}
+		}
+		defs = append(defs, def)
+	}
+	res.Defs = defs
+	return res, nil
+}
+
+// mountScenario combines the scenario and the packs it uses.
+func mountScenario(name string, scenario fs.FS, packs []Pack) (mountFS, error) {
+	data, err := fs.ReadFile(scenario, c4group.C4CFN_ScenarioCore)
+	if err != nil {
+		return nil, err
+	}
+	var scen struct{ Definitions core.ScenarioDefinitions }
+	if _, err := core.Decode(bytes.NewReader(data), &scen); err != nil {
+		return nil, fmt.Errorf("%s: %w", c4group.C4CFN_ScenarioCore, err)
+	}
+	var used map[string]bool
+	if list := scen.Definitions.List(); len(list) > 0 {
+		used = make(map[string]bool)
+		for _, def := range list {
+			def = strings.ReplaceAll(def, "\\", "/")
+			used[strings.SplitN(def, "/", 2)[0]] = true
+		}
+	}
+
+	mount := mountFS{name: scenario}
+	for _, pack := range packs {
+		if used != nil && !used[pack.Name] {
+			continue
+		}
+		if _, ok := mount[pack.Name]; ok {
+			return nil, fmt.Errorf("%s: %w", pack.Name, c4group.ErrEntryExists)
+		}
+		mount[pack.Name] = pack.FS
+	}
+	return mount, nil
+}
+
+// findDef returns the definition with the given ID from the first pack
+// containing it.
+func findDef(cat *Catalog, packs []Pack, id string) (Def, bool) {
+	for _, pack := range packs {
+		for _, def := range cat.Defs {
+			if def.ID == id && strings.HasPrefix(def.Path, pack.Name+"/") {
+				return def, true
+			}
+		}
+	}
+	return Def{}, false
+}
+
+// Export writes the scenario as packed group to w with the definitions it
+// needs from packs embedded, see Resolve. Embedded definitions are added to
+// the scenario's root, renamed to <ID>.ocd or <ID><n>.ocd if the filename is
+// taken. The scenario's files are copied unchanged.
+func Export(w io.Writer, name string, scenario fs.FS, packs []Pack) (*Resolution, error) {
+	mount, err := mountScenario(name, scenario, packs)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resolve(name, mount, packs)
+	if err != nil {
+		return nil, err
+	}
+
+	b := c4group.NewBuilder(name)
+	if err := b.AddFS(scenario, "."); err != nil {
+		return nil, err
+	}
+	entries, err := fs.ReadDir(scenario, ".")
+	if err != nil {
+		return nil, err
+	}
+	taken := make(map[string]bool)
+	for _, e := range entries {
+		taken[strings.ToLower(e.Name())] = true
+	}
+	for _, def := range res.Defs {
+		filename := path.Base(def.Path)
+		for i := 1; taken[strings.ToLower(filename)]; i++ {
+			filename = def.ID + ".ocd"
+			if i > 1 {
+				filename = fmt.Sprintf("%s%d.ocd", def.ID, i)
+			}
+		}
+		taken[strings.ToLower(filename)] = true
+		info, err := fs.Stat(mount, def.Path)
+		if err != nil {
+			return nil, err
+		}
+		sub := b.AddGroup(c4group.Entry{Filename: filename, Mtime: info.ModTime()})
+		if err := sub.AddFS(mount, def.Path); err != nil {
+			return nil, err
+		}
+	}
+	b.Sort()
+	if err := b.Pack(w); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,103 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+package defs
+
+import (
+	"bytes"
+	"io/fs"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/lluchs/c4group-go"
+)
+
+func TestExport(t *testing.T) {
+	file := func(s string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(s)}
+	}
+	objects := fstest.MapFS{
+		"Clonk.ocd/DefCore.txt":               file("[DefCore]\nid=Clonk\n"),
+		"Clonk.ocd/Script.c":                  file("#include Library_Clonk\n"),
+		"Clonk.ocd/Hat.ocd/DefCore.txt":       file("[DefCore]\nid=Hat\n"),
+		"Clonk.ocd/Hat.ocd/Script.c":          file("#appendto Clonk\n"),
+		"Libraries.ocd/Clonk.ocd/DefCore.txt": file("[DefCore]\nid=Library_Clonk\n"),
+		"Libraries.ocd/Clonk.ocd/Script.c":    file("#include Library_Gone\nfunc Init() { CreateObject(Hat); }\n"),
+		"Rock.ocd/DefCore.txt":                file("[DefCore]\nid=Rock\n"),
+		"Unused.ocd/DefCore.txt":              file("[DefCore]\nid=Unused\n"),
+	}
+	other := fstest.MapFS{
+		"Rock.ocd/DefCore.txt": file("[DefCore]\nid=Rock\nCategory=C4D_Other\n"),
+	}
+	scenario := fstest.MapFS{
+		"Scenario.txt":          file("[Player1]\nCrew=Clonk=1\n"),
+		"Objects.txt":           file("[Object]\nid=Rock\nX=10\n"),
+		"Clonk.ocd/DefCore.txt": file("[DefCore]\nid=Local\n"),
+	}
+	packs := []Pack{{"Objects.ocd", objects}, {"Other.ocd", other}}
+
+	var buf bytes.Buffer
+	res, err := Export(&buf, "Arena.ocs", scenario, packs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, def := range res.Defs {
+		paths = append(paths, def.Path)
+	}
+	wantPaths := []string{"Objects.ocd/Clonk.ocd", "Objects.ocd/Libraries.ocd/Clonk.ocd", "Objects.ocd/Rock.ocd"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("Defs: got %v, want %v", paths, wantPaths)
+	}
+	if !reflect.DeepEqual(res.Missing, []string{"Library_Gone"}) {
+		t.Errorf("Missing: got %v", res.Missing)
+	}
+
+	g, err := c4group.OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := c4group.NewFS(g)
+	want := map[string]string{
+		"Scenario.txt":                   "[Player1]\nCrew=Clonk=1\n",
+		"Clonk.ocd/DefCore.txt":          "[DefCore]\nid=Local\n",
+		"Clonk2.ocd/DefCore.txt":         "[DefCore]\nid=Clonk\n",
+		"Clonk2.ocd/Hat.ocd/DefCore.txt": "[DefCore]\nid=Hat\n",
+		"Library_Clonk.ocd/DefCore.txt":  "[DefCore]\nid=Library_Clonk\n",
+		"Rock.ocd/DefCore.txt":           "[DefCore]\nid=Rock\n",
+	}
+	for name, content := range want {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil || string(data) != content {
+			t.Errorf("%s: got %q, %v", name, data, err)
+		}
+	}
+	if _, err := fs.Stat(fsys, "Unused.ocd"); err == nil {
+		t.Error("Unused.ocd was embedded")
+	}
+
+	// Only packs listed in [Definitions] are used.
+	scenario["Scenario.txt"] = file("[Definitions]\nDefinition1=Other.ocd\n\n[Player1]\nCrew=Clonk=1\n")
+	res, err = Resolve("Arena.ocs", scenario, packs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Defs) != 1 || res.Defs[0].Path != "Other.ocd/Rock.ocd" {
+		t.Errorf("Defs with [Definitions]: got %+v", res.Defs)
+	}
+	// References are only recognized for known IDs.
+	if len(res.Missing) != 0 {
+		t.Errorf("Missing with [Definitions]: got %v", res.Missing)
+	}
+}
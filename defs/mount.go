@@ -0,0 +1,142 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+package defs
+
+import (
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mountFS combines file systems as top-level directories.
+type mountFS map[string]fs.FS
+
+var (
+	_ fs.ReadDirFS  = mountFS(nil)
+	_ fs.ReadFileFS = mountFS(nil)
+	_ fs.StatFS     = mountFS(nil)
+)
+
+// split returns the file system and path for name, which must not be ".".
+func (m mountFS) split(op, name string) (fs.FS, string, error) {
+	if !fs.ValidPath(name) {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	first, rest := name, "."
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		first, rest = name[:i], name[i+1:]
+	}
+	sub, ok := m[first]
+	if !ok {
+		return nil, "", &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return sub, rest, nil
+}
+
+func (m mountFS) Open(name string) (fs.File, error) {
+	if name == "." {
+		return &mountRoot{entries: m.entries()}, nil
+	}
+	sub, rest, err := m.split("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return sub.Open(rest)
+}
+
+func (m mountFS) Stat(name string) (fs.FileInfo, error) {
+	if name == "." {
+		return mountInfo("."), nil
+	}
+	sub, rest, err := m.split("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if rest == "." {
+		return mountInfo(name), nil
+	}
+	return fs.Stat(sub, rest)
+}
+
+func (m mountFS) ReadFile(name string) ([]byte, error) {
+	sub, rest, err := m.split("read", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(sub, rest)
+}
+
+func (m mountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name == "." {
+		return m.entries(), nil
+	}
+	sub, rest, err := m.split("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(sub, rest)
+}
+
+// entries returns the mount points sorted by name.
+func (m mountFS) entries() []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(m))
+	for name := range m {
+		entries = append(entries, mountInfo(name))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries
+}
+
+// mountInfo describes a mount point or the root directory.
+type mountInfo string
+
+func (mi mountInfo) Name() string               { return string(mi) }
+func (mi mountInfo) Size() int64                { return 0 }
+func (mi mountInfo) Mode() fs.FileMode          { return fs.ModeDir | 0555 }
+func (mi mountInfo) ModTime() time.Time         { return time.Time{} }
+func (mi mountInfo) IsDir() bool                { return true }
+func (mi mountInfo) Sys() interface{}           { return nil }
+func (mi mountInfo) Type() fs.FileMode          { return fs.ModeDir }
+func (mi mountInfo) Info() (fs.FileInfo, error) { return mi, nil }
+
+// mountRoot is the opened root directory.
+type mountRoot struct {
+	entries []fs.DirEntry
+}
+
+func (d *mountRoot) Stat() (fs.FileInfo, error) { return mountInfo("."), nil }
+func (d *mountRoot) Close() error               { return nil }
+
+func (d *mountRoot) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: fs.ErrInvalid}
+}
+
+func (d *mountRoot) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
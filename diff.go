@@ -0,0 +1,253 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DiffKind classifies differences between groups.
+type DiffKind int
+
+const (
+	Added         DiffKind = iota // entry only in the new group
+	Removed                       // entry only in the old group
+	Modified                      // different size or contents
+	Reordered                     // same entry at a different position
+	HeaderChanged                 // group header field changed
+)
+
+var diffKindNames = []string{"added", "removed", "modified", "reordered", "header"}
+
+func (k DiffKind) String() string { return diffKindNames[k] }
+
+// MarshalText implements encoding.TextMarshaler.
+func (k DiffKind) MarshalText() ([]byte, error) { return []byte(k.String()), nil }
+
+// Difference is a single change between two groups.
+type Difference struct {
+	Kind DiffKind
+	// Path is the slash-separated path of the entry. For header changes,
+	// it is the path of the group, empty for the root group.
+	Path string
+	// Old and New are the entries in the respective group, nil if missing.
+	// They are nil for header changes.
+	Old, New *Entry
+	// Field, OldValue and NewValue describe header changes, e.g.
+	// "Author".
+	Field              string
+	OldValue, NewValue string
+	// OldData and NewData contain the contents of modified files accepted
+	// by Differ.KeepData.
+	OldData, NewData []byte
+}
+
+// Differ compares groups, see Diff.
+type Differ struct {
+	// KeepData selects files whose contents are kept in modified
+	// differences, e.g. to display text changes. May be nil.
+	KeepData func(path string, e *Entry) bool
+}
+
+// Diff compares the groups a (old) and b (new) including all child groups.
+// Both readers must be positioned at the start of the group. The groups are
+// read in parallel and only hashes of the contents are kept in memory.
+func Diff(a, b *Reader) ([]Difference, error) {
+	return (&Differ{}).Diff(a, b)
+}
+
+// Diff compares two groups, see the Diff function. Differences are sorted by
+// path.
+func (d *Differ) Diff(a, b *Reader) ([]Difference, error) {
+	type result struct {
+		g   *groupSummary
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		g, err := d.summarize(b, "")
+		ch <- result{g, err}
+	}()
+	oldGroup, err := d.summarize(a, "")
+	res := <-ch
+	if err != nil {
+		return nil, err
+	}
+	if res.err != nil {
+		return nil, res.err
+	}
+	diffs := compareGroups(oldGroup, res.g, "")
+	sort.SliceStable(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+// groupSummary contains the information about a group needed for comparison.
+type groupSummary struct {
+	header  Header
+	entries []*entrySummary
+}
+
+type entrySummary struct {
+	Entry
+	hash  [sha1.Size]byte
+	group *groupSummary // for child groups
+	data  []byte        // if kept
+}
+
+func (d *Differ) summarize(r *Reader, dir string) (*groupSummary, error) {
+	g := &groupSummary{header: r.Header}
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			return g, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		e := &entrySummary{Entry: *entry}
+		p := path.Join(dir, entry.Filename)
+		if entry.IsGroup {
+			sub, err := r.ReadGroup()
+			if err != nil {
+				return nil, err
+			}
+			if e.group, err = d.summarize(sub, p); err != nil {
+				return nil, err
+			}
+		} else {
+			h := sha1.New()
+			w := io.Writer(h)
+			var buf bytes.Buffer
+			if d.KeepData != nil && d.KeepData(p, entry) {
+				w = io.MultiWriter(h, &buf)
+			}
+			if _, err := io.Copy(w, r); err != nil {
+				return nil, err
+			}
+			copy(e.hash[:], h.Sum(nil))
+			if buf.Len() > 0 {
+				e.data = buf.Bytes()
+			}
+		}
+		g.entries = append(g.entries, e)
+	}
+}
+
+func compareGroups(a, b *groupSummary, dir string) []Difference {
+	var diffs []Difference
+	header := func(field, old, new string) {
+		if old != new {
+			diffs = append(diffs, Difference{Kind: HeaderChanged, Path: dir, Field: field, OldValue: old, NewValue: new})
+		}
+	}
+	header("Author", a.header.Author, b.header.Author)
+	header("Ctime", formatTime(a.header.Ctime), formatTime(b.header.Ctime))
+	header("IsOriginal", strconv.FormatBool(a.header.IsOriginal), strconv.FormatBool(b.header.IsOriginal))
+	header("Version", fmt.Sprintf("%d.%d", a.header.Ver1, a.header.Ver2), fmt.Sprintf("%d.%d", b.header.Ver1, b.header.Ver2))
+
+	newEntries := make(map[string]*entrySummary)
+	for _, e := range b.entries {
+		newEntries[e.Filename] = e
+	}
+	oldEntries := make(map[string]*entrySummary)
+	for _, e := range a.entries {
+		oldEntries[e.Filename] = e
+	}
+	inOrder := commonOrder(a.entries, b.entries, oldEntries, newEntries)
+
+	for _, old := range a.entries {
+		p := path.Join(dir, old.Filename)
+		new, ok := newEntries[old.Filename]
+		if !ok {
+			diffs = append(diffs, Difference{Kind: Removed, Path: p, Old: &old.Entry})
+			continue
+		}
+		if !inOrder[old.Filename] {
+			diffs = append(diffs, Difference{Kind: Reordered, Path: p, Old: &old.Entry, New: &new.Entry})
+		}
+		switch {
+		case old.group != nil && new.group != nil:
+			diffs = append(diffs, compareGroups(old.group, new.group, p)...)
+		case old.IsGroup != new.IsGroup || old.Size != new.Size || old.hash != new.hash:
+			diffs = append(diffs, Difference{Kind: Modified, Path: p, Old: &old.Entry, New: &new.Entry, OldData: old.data, NewData: new.data})
+		}
+	}
+	for _, new := range b.entries {
+		if _, ok := oldEntries[new.Filename]; !ok {
+			diffs = append(diffs, Difference{Kind: Added, Path: path.Join(dir, new.Filename), New: &new.Entry})
+		}
+	}
+	return diffs
+}
+
+// commonOrder returns the entries present in both groups which keep their
+// relative order, i.e. a longest common subsequence. All other common
+// entries have been reordered.
+//
+// As entry names are unique, the common entries of b are a permutation of
+// those of a and their longest common subsequence is the longest increasing
+// subsequence of their positions in b. It is found in O(n log n) time with
+// patience sorting instead of the quadratic LCS table.
+func commonOrder(a, b []*entrySummary, inA, inB map[string]*entrySummary) map[string]bool {
+	posInB := make(map[string]int)
+	for _, e := range b {
+		if _, ok := inA[e.Filename]; ok {
+			posInB[e.Filename] = len(posInB)
+		}
+	}
+	var x []string // common entries in the order of a
+	var pos []int  // their positions in b
+	for _, e := range a {
+		if j, ok := posInB[e.Filename]; ok {
+			x = append(x, e.Filename)
+			pos = append(pos, j)
+		}
+	}
+	// tails[k] is the index in x of the smallest last element of an
+	// increasing subsequence of length k+1. prev links each element to its
+	// predecessor in such a subsequence.
+	var tails []int
+	prev := make([]int, len(x))
+	for i, p := range pos {
+		k := sort.Search(len(tails), func(k int) bool { return pos[tails[k]] >= p })
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	inOrder := make(map[string]bool)
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			inOrder[x[i]] = true
+		}
+	}
+	return inOrder
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
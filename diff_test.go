@@ -0,0 +1,110 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	big := testData(1 << 16)
+	old := writeTestGroup(t, big)
+
+	b := NewBuilder("Test.ocs")
+	b.Header.Author = "Other"
+	b.AddFile(Entry{Filename: "Big.txt", Size: len(big)}, stringFile(string(big)))
+	b.AddFile(Entry{Filename: "Scenario.txt", Size: 18}, stringFile("[Head]\nTitle=Test\n"))
+	clonk := b.AddGroup(Entry{Filename: "Objects.ocd"}).AddGroup(Entry{Filename: "Clonk.ocd"})
+	clonk.AddFile(Entry{Filename: "Script.c", Size: 22}, stringFile("#include Library_Hero\n"))
+	b.AddFile(Entry{Filename: "Added.txt", Size: 1}, stringFile("!"))
+	var buf bytes.Buffer
+	if err := b.Pack(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	ra, err := NewReader(bytes.NewReader(old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb, err := NewReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	differ := &Differ{KeepData: func(path string, e *Entry) bool {
+		return strings.HasSuffix(path, ".c")
+	}}
+	diffs, err := differ.Diff(ra, rb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diffs {
+		s := d.Kind.String() + " " + d.Path
+		if d.Kind == HeaderChanged {
+			s += " " + d.Field + " " + d.OldValue + " -> " + d.NewValue
+		}
+		got = append(got, s)
+	}
+	want := []string{
+		"header  Author Test -> Other",
+		"added Added.txt",
+		"reordered Big.txt",
+		"removed Objects.ocd/Clonk.ocd/DefCore.txt",
+		"modified Objects.ocd/Clonk.ocd/Script.c",
+	}
+	checkNames(t, "Diff", got, want...)
+	for _, d := range diffs {
+		if d.Kind == Modified && (string(d.OldData) != "#include Library_Clonk\n" || string(d.NewData) != "#include Library_Hero\n") {
+			t.Errorf("Script.c: unexpected data %q, %q", d.OldData, d.NewData)
+		}
+	}
+}
+
+func TestCommonOrder(t *testing.T) {
+	entries := func(names ...string) ([]*entrySummary, map[string]*entrySummary) {
+		var list []*entrySummary
+		m := make(map[string]*entrySummary)
+		for _, name := range names {
+			e := &entrySummary{Entry: Entry{Filename: name}}
+			list = append(list, e)
+			m[name] = e
+		}
+		return list, m
+	}
+	a, inA := entries("A", "B", "Removed", "C", "D", "E")
+	b, inB := entries("B", "C", "Added", "A", "E", "D")
+	inOrder := commonOrder(a, b, inA, inB)
+	if len(inOrder) != 3 || !inOrder["B"] || !inOrder["C"] || inOrder["A"] || inOrder["Removed"] {
+		t.Errorf("got %v", inOrder)
+	}
+
+	// Large groups don't need quadratic memory.
+	const n = 20000
+	names := make([]string, n)
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+	}
+	a, inA = entries(names...)
+	for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	b, inB = entries(names...)
+	if inOrder := commonOrder(a, b, inA, inB); len(inOrder) != 1 {
+		t.Errorf("reversed: got %d entries in order, want 1", len(inOrder))
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// DirGroup is an unpacked group directory. Like in the engine,
+// subdirectories and packed group files in it are child groups. Entries are
+// sorted with NameLess. The directory tree is read when opening, later
+// changes on disk aren't reflected in List.
+type DirGroup struct {
+	dir string
+	b   *Builder
+}
+
+// OpenDir opens the group directory at dir.
+func OpenDir(dir string) (*DirGroup, error) {
+	b := NewBuilder(filepath.Base(dir))
+	if err := b.AddDir(dir, nil); err != nil {
+		return nil, err
+	}
+	b.Sort()
+	return &DirGroup{dir: dir, b: b}, nil
+}
+
+// GroupHeader implements Archive.
+func (g *DirGroup) GroupHeader() Header {
+	hdr := g.b.header()
+	hdr.Ver1, hdr.Ver2 = C4GroupFileVer1, C4GroupFileVer2
+	return *hdr
+}
+
+// List implements Archive. The size of child groups is their size when packed
+// without compression.
+func (g *DirGroup) List() []Entry {
+	entries := make([]Entry, len(g.b.entries))
+	for i, e := range g.b.entries {
+		entries[i] = e.Entry
+		entries[i].Size = e.size()
+	}
+	return entries
+}
+
+// OpenEntry implements Archive. Subdirectories are packed on the fly.
+func (g *DirGroup) OpenEntry(name string) (io.ReadCloser, error) {
+	if dir, rest, ok := splitFirst(name); ok {
+		child, err := g.OpenChild(dir)
+		if err != nil {
+			return nil, err
+		}
+		r, err := child.OpenEntry(rest)
+		if err != nil {
+			child.Close()
+			return nil, err
+		}
+		return &ownedReader{r, child}, nil
+	}
+	i := g.b.find(name)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	e := g.b.entries[i]
+	if e.group == nil {
+		return e.open()
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(e.group.PackRaw(pw))
+	}()
+	return pr, nil
+}
+
+// OpenChild implements Archive. Packed child groups are opened as Group and
+// closed with the returned group.
+func (g *DirGroup) OpenChild(name string) (Archive, error) {
+	if dir, rest, ok := splitFirst(name); ok {
+		child, err := g.OpenChild(dir)
+		if err != nil {
+			return nil, err
+		}
+		sub, err := child.OpenChild(rest)
+		if err != nil {
+			child.Close()
+			return nil, err
+		}
+		return &ownedGroup{sub, child}, nil
+	}
+	i := g.b.find(name)
+	if i < 0 {
+		return nil, ErrNotFound
+	}
+	e := g.b.entries[i]
+	if !e.IsGroup {
+		return nil, ErrNoChildGroup
+	}
+	p := filepath.Join(g.dir, name)
+	if e.group != nil {
+		return &DirGroup{dir: p, b: e.group}, nil
+	}
+	return openFile(p)
+}
+
+// Close implements Archive. Directories don't need to be closed.
+func (g *DirGroup) Close() error {
+	return nil
+}
+
+// splitFirst splits the first component off a slash-separated path.
+func splitFirst(name string) (first, rest string, ok bool) {
+	i := strings.IndexByte(name, '/')
+	if i < 0 {
+		return name, "", false
+	}
+	return name[:i], name[i+1:], true
+}
+
+// ownedGroup closes the group it was opened from with itself.
+type ownedGroup struct {
+	Archive
+	owner Archive
+}
+
+func (g *ownedGroup) Close() error {
+	g.Archive.Close()
+	return g.owner.Close()
+}
+
+// ownedReader closes the group it was opened from with itself.
+type ownedReader struct {
+	io.ReadCloser
+	owner Archive
+}
+
+func (r *ownedReader) Close() error {
+	r.ReadCloser.Close()
+	return r.owner.Close()
+}
@@ -0,0 +1,136 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestGroupInterface(t *testing.T) {
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	dir := filepath.Join(t.TempDir(), "Test.ocs")
+	writeTestDir(t, dir, map[string]string{
+		"Script.c":                         "func Initialize() {}",
+		"Scenario.txt":                     "[Head]\n",
+		"Objects.ocd/Rock.ocd/DefCore.txt": "[DefCore]\nid=Rock\n",
+		"Objects.ocd/Rock.ocd/Script.c":    "#appendto Rock\n",
+		"Packed.ocd/Wipf.ocd/DefCore.txt":  "[DefCore]\nid=Wipf\n",
+	})
+	// Packed.ocd is a packed group file inside the directory.
+	packedDir := filepath.Join(dir, "Packed.ocd")
+	var packed bytes.Buffer
+	check(PackDir(&packed, packedDir, nil))
+	check(os.RemoveAll(packedDir))
+	check(os.WriteFile(packedDir, packed.Bytes(), 0644))
+
+	file := filepath.Join(t.TempDir(), "Test.ocs")
+	var buf bytes.Buffer
+	check(PackDir(&buf, dir, nil))
+	check(os.WriteFile(file, buf.Bytes(), 0644))
+
+	for _, p := range []string{dir, file} {
+		g, err := Open(p)
+		check(err)
+		var names []string
+		for _, e := range g.List() {
+			names = append(names, e.Filename)
+		}
+		checkNames(t, p, names, "Scenario.txt", "Objects.ocd", "Packed.ocd", "Script.c")
+		if hdr := g.GroupHeader(); hdr.Entries != 4 || hdr.Ver1 != 1 || hdr.Ver2 != 2 {
+			t.Errorf("%s: got header %+v", p, hdr)
+		}
+
+		for _, name := range []string{"Objects.ocd/Rock.ocd/Script.c", "Packed.ocd/Wipf.ocd/DefCore.txt"} {
+			r, err := g.OpenEntry(name)
+			check(err)
+			data, err := ioutil.ReadAll(r)
+			check(err)
+			check(r.Close())
+			if want := map[string]string{"Objects.ocd/Rock.ocd/Script.c": "#appendto Rock\n", "Packed.ocd/Wipf.ocd/DefCore.txt": "[DefCore]\nid=Wipf\n"}[name]; string(data) != want {
+				t.Errorf("%s: %s: got %q, want %q", p, name, data, want)
+			}
+		}
+
+		// Child groups are raw streams.
+		r, err := g.OpenEntry("Objects.ocd")
+		check(err)
+		cr, err := NewRawReader(r)
+		check(err)
+		checkNames(t, p+": raw Objects.ocd", []string{cr.Entries[0].Filename}, "Rock.ocd")
+		io.Copy(ioutil.Discard, r)
+		check(r.Close())
+
+		child, err := g.OpenChild("Packed.ocd/Wipf.ocd")
+		check(err)
+		if entries := child.List(); len(entries) != 1 || entries[0].Filename != "DefCore.txt" {
+			t.Errorf("%s: Packed.ocd/Wipf.ocd: got %v", p, entries)
+		}
+		check(child.Close())
+		if _, err := g.OpenChild("Script.c"); err != ErrNoChildGroup {
+			t.Errorf("%s: expected ErrNoChildGroup, got %v", p, err)
+		}
+		if _, err := g.OpenEntry("Missing.txt"); err != ErrNotFound {
+			t.Errorf("%s: expected ErrNotFound, got %v", p, err)
+		}
+
+		// Packed child groups are directories in the file system view.
+		fsys := NewFS(g)
+		if err := fstest.TestFS(fsys, "Packed.ocd/Wipf.ocd/DefCore.txt", "Objects.ocd/Rock.ocd/Script.c"); err != nil {
+			t.Errorf("%s: %v", p, err)
+		}
+		check(fsys.Close())
+		check(g.Close())
+	}
+}
+
+func TestRawStreams(t *testing.T) {
+	b := NewBuilder("Test.ocd")
+	b.AddFile(Entry{Filename: "DefCore.txt", Size: 9}, stringFile("[DefCore]"))
+	var buf bytes.Buffer
+	if err := b.PackRaw(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != b.Size() {
+		t.Errorf("raw stream has %d bytes, want %d", buf.Len(), b.Size())
+	}
+	g, err := OpenRawReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readTestEntry(t, g, "DefCore.txt"); string(got) != "[DefCore]" {
+		t.Errorf("DefCore.txt: got %q", got)
+	}
+	r, err := NewRawReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadAll(r); err != nil || string(data) != "[DefCore]" {
+		t.Errorf("DefCore.txt: got %q, %v", data, err)
+	}
+}
@@ -0,0 +1,328 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+var ErrEntryExists error = errors.New("c4group: entry already exists")
+
+// Editor modifies an existing group. Entries are addressed with
+// slash-separated paths like in Group.Lookup. Untouched entries, including
+// whole child groups, are copied from the source group when writing.
+//
+// New entries are inserted at their NameLess position, all other entries
+// keep their order.
+type Editor struct {
+	root *Builder
+}
+
+// NewEditor creates an editor for the group src. The name of the group
+// determines the sort order of new entries.
+func NewEditor(name string, src *Group) *Editor {
+	return &Editor{root: builderFromGroup(name, src)}
+}
+
+// builderFromGroup returns a Builder which copies all entries of g.
+func builderFromGroup(name string, g *Group) *Builder {
+	b := NewBuilder(name)
+	b.Header = g.Header
+	for i := range g.Entries {
+		i := i
+		b.entries = append(b.entries, &builderEntry{
+			Entry: g.Entries[i],
+			open: func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(g.section(i)), nil
+			},
+			source: g,
+			index:  i,
+		})
+	}
+	return b
+}
+
+// find returns the index of the entry with the given name, or -1.
+func (b *Builder) find(name string) int {
+	for i, e := range b.entries {
+		if e.Filename == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// insert adds an entry at its sorted position.
+func (b *Builder) insert(e *builderEntry) {
+	less := NameLess(b.name)
+	i := 0
+	for i < len(b.entries) && !less(e.Filename, b.entries[i].Filename) {
+		i++
+	}
+	b.entries = append(b.entries, nil)
+	copy(b.entries[i+1:], b.entries[i:])
+	b.entries[i] = e
+}
+
+// expand makes a copied child group editable.
+func (e *builderEntry) expand() error {
+	if e.group != nil {
+		return nil
+	}
+	if !e.IsGroup {
+		return ErrNoChildGroup
+	}
+	if e.source == nil {
+		return errors.New("c4group: cannot edit added child group data")
+	}
+	child, err := e.source.child(e.index)
+	if err != nil {
+		return err
+	}
+	e.group = builderFromGroup(e.Filename, child)
+	e.open = nil
+	return nil
+}
+
+// dir returns the group containing the entry at path and the entry's
+// filename. Missing child groups are created if create is set. The checksums
+// of all child groups on the way are invalidated.
+func (ed *Editor) dir(path string, create bool) (*Builder, string, error) {
+	parts := strings.Split(path, "/")
+	b := ed.root
+	for _, name := range parts[:len(parts)-1] {
+		i := b.find(name)
+		if i < 0 {
+			if !create {
+				return nil, "", ErrNotFound
+			}
+			if err := CheckFilename(name); err != nil {
+				return nil, "", err
+			}
+			e := &builderEntry{Entry: Entry{Filename: name, IsGroup: true}, group: NewBuilder(name)}
+			b.insert(e)
+			b = e.group
+			continue
+		}
+		e := b.entries[i]
+		if err := e.expand(); err != nil {
+			return nil, "", err
+		}
+		e.HasCRC = false
+		b = e.group
+	}
+	return b, parts[len(parts)-1], nil
+}
+
+// Add adds a new file. The entry's Filename is replaced with the last
+// element of path and its Size must match the data returned by open.
+// Missing child groups are created.
+func (ed *Editor) Add(path string, e Entry, open func() (io.ReadCloser, error)) error {
+	b, name, err := ed.dir(path, true)
+	if err != nil {
+		return err
+	}
+	if err := CheckFilename(name); err != nil {
+		return err
+	}
+	if b.find(name) >= 0 {
+		return ErrEntryExists
+	}
+	e.Filename = name
+	b.insert(&builderEntry{Entry: e, open: open})
+	return nil
+}
+
+// AddGroup adds a new empty child group and returns a Builder to add its
+// entries.
+func (ed *Editor) AddGroup(path string) (*Builder, error) {
+	b, name, err := ed.dir(path, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := CheckFilename(name); err != nil {
+		return nil, err
+	}
+	if b.find(name) >= 0 {
+		return nil, ErrEntryExists
+	}
+	e := &builderEntry{Entry: Entry{Filename: name, IsGroup: true}, group: NewBuilder(name)}
+	b.insert(e)
+	return e.group, nil
+}
+
+// AddPath adds the file or directory at diskPath. Directories and packed
+// groups become child groups, see PackDir.
+func (ed *Editor) AddPath(path, diskPath string) error {
+	b, name, err := ed.dir(path, true)
+	if err != nil {
+		return err
+	}
+	if err := CheckFilename(name); err != nil {
+		return err
+	}
+	if b.find(name) >= 0 {
+		return ErrEntryExists
+	}
+	tmp := NewBuilder(b.name)
+	if err := tmp.addPath(diskPath, name, name, nil); err != nil {
+		return err
+	}
+	tmp.Sort()
+	b.insert(tmp.entries[0])
+	return nil
+}
+
+// Replace replaces an existing entry with a file, keeping its position.
+func (ed *Editor) Replace(path string, e Entry, open func() (io.ReadCloser, error)) error {
+	b, name, err := ed.dir(path, false)
+	if err != nil {
+		return err
+	}
+	i := b.find(name)
+	if i < 0 {
+		return ErrNotFound
+	}
+	e.Filename = name
+	b.entries[i] = &builderEntry{Entry: e, open: open}
+	return nil
+}
+
+// ReplacePath replaces an existing entry with the file or directory at
+// diskPath, keeping its position.
+func (ed *Editor) ReplacePath(path, diskPath string) error {
+	b, name, err := ed.dir(path, false)
+	if err != nil {
+		return err
+	}
+	i := b.find(name)
+	if i < 0 {
+		return ErrNotFound
+	}
+	tmp := NewBuilder(b.name)
+	if err := tmp.addPath(diskPath, name, name, nil); err != nil {
+		return err
+	}
+	tmp.Sort()
+	b.entries[i] = tmp.entries[0]
+	return nil
+}
+
+// Delete removes an entry. Child groups are removed with all their
+// contents.
+func (ed *Editor) Delete(path string) error {
+	b, name, err := ed.dir(path, false)
+	if err != nil {
+		return err
+	}
+	i := b.find(name)
+	if i < 0 {
+		return ErrNotFound
+	}
+	b.entries = append(b.entries[:i], b.entries[i+1:]...)
+	return nil
+}
+
+// Rename changes the filename of an entry. The entry stays in the same
+// group and keeps its position.
+func (ed *Editor) Rename(path, newName string) error {
+	if err := CheckFilename(newName); err != nil {
+		return err
+	}
+	b, name, err := ed.dir(path, false)
+	if err != nil {
+		return err
+	}
+	i := b.find(name)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if b.find(newName) >= 0 {
+		return ErrEntryExists
+	}
+	e := b.entries[i]
+	e.Filename = newName
+	if e.group != nil {
+		e.group.name = newName
+	}
+	if !e.IsGroup {
+		// The checksum includes the filename.
+		e.HasCRC = false
+	}
+	return nil
+}
+
+// Sort orders the entries of the group at path (empty for the root group)
+// by the given sort list, see SortListLess. If list is empty, the sort list
+// is chosen by the group's name like in NameLess.
+func (ed *Editor) Sort(path, list string) error {
+	b := ed.root
+	if path != "" {
+		parent, name, err := ed.dir(path, false)
+		if err != nil {
+			return err
+		}
+		i := parent.find(name)
+		if i < 0 {
+			return ErrNotFound
+		}
+		e := parent.entries[i]
+		if err := e.expand(); err != nil {
+			return err
+		}
+		b = e.group
+	}
+	order := GroupSortOrder(b.name)
+	if list != "" {
+		order = CompileSortList(list)
+	}
+	b.sortBy(order)
+	return nil
+}
+
+// SortAll orders the entries of all groups with NameLess like Sort, each
+// child group by its own filename. File data is still copied from the source
+// group when writing.
+func (ed *Editor) SortAll() error {
+	if err := ed.root.expandAll(); err != nil {
+		return err
+	}
+	ed.root.Sort()
+	return nil
+}
+
+// expandAll makes all copied child groups editable recursively.
+func (b *Builder) expandAll() error {
+	for _, e := range b.entries {
+		if !e.IsGroup {
+			continue
+		}
+		if err := e.expand(); err != nil {
+			return err
+		}
+		if err := e.group.expandAll(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pack writes the modified group as packed group file to w.
+func (ed *Editor) Pack(w io.Writer) error {
+	return ed.root.Pack(w)
+}
@@ -0,0 +1,108 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func entryNames(g *Group) []string {
+	var names []string
+	for _, e := range g.Entries {
+		names = append(names, e.Filename)
+	}
+	return names
+}
+
+func checkNames(t *testing.T, what string, got []string, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+		return
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: got %v, want %v", what, got, want)
+			return
+		}
+	}
+}
+
+func TestEditor(t *testing.T) {
+	big := testData(1 << 16)
+	data := writeTestGroup(t, big)
+	src, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := NewEditor("Test.ocs", src)
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(ed.Add("Objects.ocd/Clonk.ocd/Graphics.png", Entry{Size: 3}, stringFile("PNG")))
+	check(ed.Add("Objects.ocd/Rock.ocd/DefCore.txt", Entry{Size: 7}, stringFile("id=Rock")))
+	check(ed.Rename("Objects.ocd/Clonk.ocd/DefCore.txt", "Names.txt"))
+	check(ed.Replace("Scenario.txt", Entry{Size: 6}, stringFile("[Head]")))
+	check(ed.Delete("Objects.ocd/Clonk.ocd/Script.c"))
+	if err := ed.Add("Scenario.txt", Entry{}, stringFile("")); err != ErrEntryExists {
+		t.Errorf("expected ErrEntryExists, got %v", err)
+	}
+	if err := ed.Delete("Objects.ocd/Wipf.ocd"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if err := ed.Rename("Scenario.txt", "../Scenario.txt"); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("expected ErrUnsafePath, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	check(ed.Pack(&buf))
+	g, err := OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	check(err)
+	checkNames(t, "root", entryNames(g), "Scenario.txt", "Objects.ocd", "Big.txt")
+	objects, err := g.OpenGroup("Objects.ocd")
+	check(err)
+	checkNames(t, "Objects.ocd", entryNames(objects), "Clonk.ocd", "Rock.ocd")
+	clonk, err := g.OpenGroup("Objects.ocd/Clonk.ocd")
+	check(err)
+	checkNames(t, "Clonk.ocd", entryNames(clonk), "Graphics.png", "Names.txt")
+	if got := readTestEntry(t, g, "Scenario.txt"); string(got) != "[Head]" {
+		t.Errorf("Scenario.txt: got %q", got)
+	}
+	if got := readTestEntry(t, g, "Objects.ocd/Clonk.ocd/Names.txt"); string(got) != "[DefCore]\nid=Clonk\n" {
+		t.Errorf("Names.txt: got %q", got)
+	}
+	if got := readTestEntry(t, g, "Objects.ocd/Rock.ocd/DefCore.txt"); string(got) != "id=Rock" {
+		t.Errorf("DefCore.txt: got %q", got)
+	}
+	if !bytes.Equal(readTestEntry(t, g, "Big.txt"), big) {
+		t.Error("Big.txt: data mismatch")
+	}
+
+	// Untouched child groups are copied as is.
+	ed = NewEditor("Test.ocs", src)
+	check(ed.Delete("Big.txt"))
+	buf.Reset()
+	check(ed.Pack(&buf))
+	g, err = OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	check(err)
+	if !bytes.Equal(readTestEntry(t, g, "Objects.ocd"), readTestEntry(t, src, "Objects.ocd")) {
+		t.Error("Objects.ocd: data changed")
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrUnsafePath error = errors.New("c4group: unsafe entry filename")
+
+// ExtractOptions configures Extract.
+type ExtractOptions struct {
+	// PackChildGroups writes child groups as packed group files instead of
+	// directories.
+	PackChildGroups bool
+	// Overwrite allows replacing existing files and extracting into
+	// existing directories.
+	Overwrite bool
+}
+
+// CheckFilename returns an error wrapping ErrUnsafePath if the entry
+// filename can't safely be used as file name, e.g. because it contains path
+// separators or NUL bytes. Names read from groups end at the first NUL byte,
+// but names passed to Editor would otherwise be truncated silently.
+func CheckFilename(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, "/\\\x00") || filepath.VolumeName(name) != "" {
+		return fmt.Errorf("%w: %q", ErrUnsafePath, name)
+	}
+	return nil
+}
+
+// Extract unpacks all remaining entries of r recursively into the directory
+// destDir, which is created if necessary. Executable flags and modification
+// times are restored. opts may be nil.
+func Extract(r *Reader, destDir string, opts *ExtractOptions) error {
+	if opts == nil {
+		opts = &ExtractOptions{}
+	}
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return err
+	}
+	return extractEntries(r, destDir, opts)
+}
+
+func extractEntries(r *Reader, dir string, opts *ExtractOptions) error {
+	for {
+		entry, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := CheckFilename(entry.Filename); err != nil {
+			return err
+		}
+		path := filepath.Join(dir, entry.Filename)
+		if entry.IsGroup && !opts.PackChildGroups {
+			err = extractGroup(r, path, opts)
+		} else {
+			err = extractFile(r, entry, path, opts)
+		}
+		if err != nil {
+			return err
+		}
+		if entry.Mtime.Unix() > 0 {
+			if err := os.Chtimes(path, entry.Mtime, entry.Mtime); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// extractGroup unpacks the current child group of r into a directory.
+func extractGroup(r *Reader, path string, opts *ExtractOptions) error {
+	if err := os.Mkdir(path, 0755); err != nil {
+		// Only merge into real directories, never follow symlinks.
+		info, lerr := os.Lstat(path)
+		if !opts.Overwrite || lerr != nil || !info.IsDir() {
+			return err
+		}
+	}
+	sub, err := r.ReadGroup()
+	if err != nil {
+		return err
+	}
+	return extractEntries(sub, path, opts)
+}
+
+// extractFile writes the current entry of r to a file. Child groups are
+// compressed to packed group files.
+func extractFile(r *Reader, entry *Entry, path string, opts *ExtractOptions) (err error) {
+	if opts.Overwrite {
+		// Remove instead of truncating so that we don't write through
+		// symlinks.
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	var perm os.FileMode = 0644
+	if entry.Executable {
+		perm = 0755
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if entry.IsGroup {
+		gz := gzip.NewWriter(&magicBytesWriter{w: f})
+		if _, err := io.Copy(gz, r); err != nil {
+			return err
+		}
+		return gz.Close()
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
@@ -0,0 +1,142 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExtract(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "Test.ocs")
+	writeTestDir(t, src, map[string]string{
+		"Script.c":                      "func Initialize() {}",
+		"Objects.ocd/Rock.ocd/Script.c": "#appendto Rock\n",
+	})
+	mtime := time.Unix(1500000000, 0)
+	if err := os.Chtimes(filepath.Join(src, "Script.c"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(src, "Script.c"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := PackDir(&buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	extract := func(dest string, opts *ExtractOptions) error {
+		r, err := NewReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return Extract(r, dest, opts)
+	}
+
+	dest := filepath.Join(t.TempDir(), "out")
+	if err := extract(dest, nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dest, "Objects.ocd", "Rock.ocd", "Script.c"))
+	if err != nil || string(b) != "#appendto Rock\n" {
+		t.Errorf("Script.c: got %q, %v", b, err)
+	}
+	info, err := os.Stat(filepath.Join(dest, "Script.c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&0111 == 0 {
+		t.Error("Script.c: executable flag not restored")
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("Script.c: got mtime %v, want %v", info.ModTime(), mtime)
+	}
+
+	if err := extract(dest, nil); !errors.Is(err, os.ErrExist) {
+		t.Errorf("expected ErrExist when extracting twice, got %v", err)
+	}
+	if err := extract(dest, &ExtractOptions{Overwrite: true}); err != nil {
+		t.Errorf("overwriting failed: %v", err)
+	}
+
+	packedDest := filepath.Join(t.TempDir(), "packed")
+	if err := extract(packedDest, &ExtractOptions{PackChildGroups: true}); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(packedDest, "Objects.ocd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, _ = f.Stat()
+	g, err := OpenReaderAt(f, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Lookup("Rock.ocd/Script.c"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestExtractUnsafe(t *testing.T) {
+	for _, name := range []string{"../evil.txt", "/etc/evil", "..", "a\\b"} {
+		var buf bytes.Buffer
+		cw := NewWriter(&buf)
+		cw.WriteHeader(&Header{Entries: 1})
+		cw.WriteEntry(&Entry{Filename: name, Size: 4})
+		io.WriteString(cw, "evil")
+		cw.Close()
+
+		r, err := NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dest := t.TempDir()
+		if err := Extract(r, dest, nil); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%q: expected ErrUnsafePath, got %v", name, err)
+		}
+	}
+}
+
+func TestCheckFilename(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "a/b", "a\\b", "Script\x00.c"} {
+		if err := CheckFilename(name); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("%q: expected ErrUnsafePath, got %v", name, err)
+		}
+	}
+	if err := CheckFilename("Script.c"); err != nil {
+		t.Errorf("Script.c: %v", err)
+	}
+
+	// Names read from groups can't contain NUL bytes, but names of new
+	// entries can.
+	data := writeTestGroup(t, []byte("big"))
+	src, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed := NewEditor("Test.ocs", src)
+	if err := ed.Add("Evil\x00.txt", Entry{Size: 4}, stringFile("evil")); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Add: expected ErrUnsafePath, got %v", err)
+	}
+	if err := ed.Rename("Big.txt", "Big\x00.txt"); !errors.Is(err, ErrUnsafePath) {
+		t.Errorf("Rename: expected ErrUnsafePath, got %v", err)
+	}
+}
@@ -31,6 +31,13 @@ const (
 
 const originalMagic = 1234567
 
+// Values of the HasCRC entry field (C4GECS_*)
+const (
+	crcNone = 0
+	crcOld  = 1 // CRC of the file contents
+	crcNew  = 2 // CRC of the file contents and filename
+)
+
 // Header on-disk format (C4GroupHeader)
 type header struct {
 	ID         [24 + 4]byte
@@ -71,6 +78,14 @@ type Header struct {
 	Author     string    // reserved in OpenClonk
 	Ctime      time.Time // creation time, reserved in OpenClonk
 	IsOriginal bool      // reserved in OpenClonk
+
+	// Ver1 and Ver2 are the format version, 1.2 for all current groups.
+	// Groups 1.0 and 1.1 of old Clonk versions have no entry timestamps
+	// and checksums. Writers use the current version if Ver1 is 0.
+	Ver1, Ver2 int32
+	// Unscrambled is set if the header is stored without memScramble, as
+	// written by some third-party tools.
+	Unscrambled bool
 }
 
 // Entry contains the public C4GroupEntryCore fields.
@@ -80,6 +95,8 @@ type Entry struct {
 	Size       int
 	Mtime      time.Time // modification time, reserved in openclonk
 	Executable bool
+	HasCRC     bool   // reserved in OpenClonk
+	CRC        uint32 // see FileCRC and ContentsCRC, reserved in OpenClonk
 }
 
 // i2b converts an integer to a boolean.
@@ -108,9 +125,19 @@ func clen(n []byte) int {
 	return len(n)
 }
 
+// unixTime converts a time to the on-disk format. The zero time is stored as 0.
+func unixTime(t time.Time) int32 {
+	if t.IsZero() {
+		return 0
+	}
+	return int32(t.Unix())
+}
+
 // publicHeader adapts Header fields from file format to Go API format.
 func publicHeader(public *Header, private *header) {
 	public.Entries = private.Entries
+	public.Ver1 = private.Ver1
+	public.Ver2 = private.Ver2
 	public.Author = string(private.Author[:clen(private.Author[:])])
 	public.Ctime = time.Unix(int64(private.Ctime), 0)
 	public.IsOriginal = private.Original == originalMagic
@@ -119,8 +146,12 @@ func publicHeader(public *Header, private *header) {
 // privateHeader adapts header fields from Go API format to file format.
 func privateHeader(private *header, public *Header) {
 	private.Entries = public.Entries
+	private.Ver1, private.Ver2 = public.Ver1, public.Ver2
+	if public.Ver1 == 0 {
+		private.Ver1, private.Ver2 = C4GroupFileVer1, C4GroupFileVer2
+	}
 	copy(private.Author[:], []byte(public.Author))
-	private.Ctime = int32(public.Ctime.Unix())
+	private.Ctime = unixTime(public.Ctime)
 	if public.IsOriginal {
 		private.Original = originalMagic
 	} else {
@@ -136,6 +167,24 @@ func publicEntry(public *Entry, private *entry) {
 	public.Size = int(private.Size)
 	public.Mtime = time.Unix(int64(private.Mtime), 0)
 	public.Executable = i2b(int(private.Executable))
+	public.HasCRC = private.HasCRC != crcNone
+	public.CRC = private.CRC
+}
+
+// legacyEntry clears the fields of an entry core which are undefined in
+// group versions before 1.2. Unknown checksum types written by other tools
+// are ignored in all versions.
+func legacyEntry(private *entry, ver2 int32) {
+	if ver2 < C4GroupFileVer2 {
+		private.Mtime = 0
+		private.HasCRC = crcNone
+		private.CRC = 0
+		private.Executable = 0
+	}
+	if private.HasCRC > crcNew {
+		private.HasCRC = crcNone
+		private.CRC = 0
+	}
 }
 
 // privateHeader adapts Header fields from Go API format to file format.
@@ -143,6 +192,13 @@ func privateEntry(private *entry, public *Entry) {
 	copy(private.Filename[:], public.Filename)
 	private.ChildGroup = int32(b2i(public.IsGroup))
 	private.Size = int32(public.Size)
-	private.Mtime = int32(public.Mtime.Unix())
+	private.Mtime = unixTime(public.Mtime)
 	private.Executable = byte(b2i(public.Executable))
+	if public.HasCRC {
+		private.HasCRC = crcNew
+		private.CRC = public.CRC
+	} else {
+		private.HasCRC = crcNone
+		private.CRC = 0
+	}
 }
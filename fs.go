@@ -0,0 +1,296 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FS provides a read-only file system view of a group. Child groups appear as
+// directories. Child groups are opened once and kept open until Close.
+type FS struct {
+	a Archive
+
+	mu     sync.Mutex
+	groups map[string]*fsGroup // opened groups by path, "." is the group passed to NewFS
+}
+
+// fsGroup caches the entry list of an opened group.
+type fsGroup struct {
+	a       Archive
+	entries []Entry
+}
+
+var (
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+)
+
+// NewFS returns a file system backed by the group a, e.g. a packed group
+// or an unpacked directory opened with Open.
+func NewFS(a Archive) *FS {
+	return &FS{a: a}
+}
+
+// Close closes the child groups opened by the file system. The group passed
+// to NewFS isn't closed.
+func (f *FS) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var err error
+	for name, g := range f.groups {
+		if name == "." {
+			continue
+		}
+		if cerr := g.a.Close(); err == nil {
+			err = cerr
+		}
+	}
+	f.groups = nil
+	return err
+}
+
+// fsError converts lookup errors to the io/fs equivalents.
+func fsError(op, name string, err error) error {
+	switch err {
+	case ErrNotFound:
+		err = fs.ErrNotExist
+	case ErrNoChildGroup:
+		err = errors.New("not a directory")
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// group returns the group at the slash-separated path dir, opening it if
+// necessary.
+func (f *FS) group(dir string) (*fsGroup, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.groupLocked(dir)
+}
+
+func (f *FS) groupLocked(dir string) (*fsGroup, error) {
+	if g, ok := f.groups[dir]; ok {
+		return g, nil
+	}
+	a := f.a
+	if dir != "." {
+		parent, err := f.groupLocked(path.Dir(dir))
+		if err != nil {
+			return nil, err
+		}
+		if a, err = parent.a.OpenChild(path.Base(dir)); err != nil {
+			return nil, err
+		}
+	}
+	g := &fsGroup{a: a, entries: a.List()}
+	if f.groups == nil {
+		f.groups = make(map[string]*fsGroup)
+	}
+	f.groups[dir] = g
+	return g, nil
+}
+
+// lookup returns the group containing name and the entry. The root
+// directory has a nil entry.
+func (f *FS) lookup(op, name string) (*fsGroup, *Entry, error) {
+	if !fs.ValidPath(name) {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return nil, nil, nil
+	}
+	parent, err := f.group(path.Dir(name))
+	if err != nil {
+		return nil, nil, fsError(op, name, err)
+	}
+	base := path.Base(name)
+	for i := range parent.entries {
+		if parent.entries[i].Filename == base {
+			return parent, &parent.entries[i], nil
+		}
+	}
+	return nil, nil, fsError(op, name, ErrNotFound)
+}
+
+// Open opens the named file or child group.
+func (f *FS) Open(name string) (fs.File, error) {
+	parent, e, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	info := f.info(e)
+	if e == nil || e.IsGroup {
+		sub, err := f.group(name)
+		if err != nil {
+			return nil, fsError("open", name, err)
+		}
+		return &groupDir{info: info, g: sub}, nil
+	}
+	r, err := parent.a.OpenEntry(e.Filename)
+	if err != nil {
+		return nil, fsError("open", name, err)
+	}
+	return &groupFile{ReadCloser: r, info: info}, nil
+}
+
+// Stat returns file information for the named file or child group.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	_, e, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return f.info(e), nil
+}
+
+// ReadFile reads the named file.
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	parent, e, err := f.lookup("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.IsGroup {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: errors.New("is a directory")}
+	}
+	r, err := parent.a.OpenEntry(e.Filename)
+	if err != nil {
+		return nil, fsError("readfile", name, err)
+	}
+	defer r.Close()
+	// The size from the entry header isn't trusted for allocation, a small
+	// packed group can claim huge entries.
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(e.Size)))
+	if err == nil && len(b) < e.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+	return b, nil
+}
+
+// ReadDir reads the named child group, returning its entries sorted by
+// filename.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	_, e, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if e != nil && !e.IsGroup {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	sub, err := f.group(name)
+	if err != nil {
+		return nil, fsError("readdir", name, err)
+	}
+	entries := dirEntries(sub.entries)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// info returns file information for an entry. A nil entry refers to the
+// root group.
+func (f *FS) info(e *Entry) *fileInfo {
+	if e == nil {
+		return &fileInfo{
+			entry: Entry{Filename: ".", IsGroup: true, Mtime: f.a.GroupHeader().Ctime},
+		}
+	}
+	return &fileInfo{entry: *e}
+}
+
+// dirEntries converts group entries in group order.
+func dirEntries(list []Entry) []fs.DirEntry {
+	entries := make([]fs.DirEntry, len(list))
+	for i := range list {
+		entries[i] = fs.FileInfoToDirEntry(&fileInfo{entry: list[i]})
+	}
+	return entries
+}
+
+// fileInfo implements fs.FileInfo for group entries.
+type fileInfo struct {
+	entry Entry
+}
+
+func (fi *fileInfo) Name() string       { return path.Base(fi.entry.Filename) }
+func (fi *fileInfo) Size() int64        { return int64(fi.entry.Size) }
+func (fi *fileInfo) ModTime() time.Time { return fi.entry.Mtime }
+func (fi *fileInfo) IsDir() bool        { return fi.entry.IsGroup }
+
+// Sys returns the underlying *Entry.
+func (fi *fileInfo) Sys() interface{} { return &fi.entry }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	switch {
+	case fi.entry.IsGroup:
+		return fs.ModeDir | 0755
+	case fi.entry.Executable:
+		return 0755
+	default:
+		return 0644
+	}
+}
+
+// groupFile is an open regular file.
+type groupFile struct {
+	io.ReadCloser
+	info *fileInfo
+}
+
+func (f *groupFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+// groupDir is an open child group.
+type groupDir struct {
+	info    *fileInfo
+	g       *fsGroup
+	entries []fs.DirEntry // remaining entries for ReadDir, nil before first call
+}
+
+func (d *groupDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *groupDir) Close() error               { return nil }
+
+func (d *groupDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+// ReadDir returns the entries of the child group in group order.
+func (d *groupDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		d.entries = dirEntries(d.g.entries)
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = d.entries[len(d.entries):]
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,102 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"runtime"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFS(t *testing.T) {
+	data := writeTestGroup(t, []byte("big"))
+	g, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := NewFS(g)
+	if err := fstest.TestFS(fsys, "Scenario.txt", "Big.txt", "Objects.ocd/Clonk.ocd/DefCore.txt", "Objects.ocd/Clonk.ocd/Script.c"); err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", "Big.txt", "Objects.ocd", "Objects.ocd/Clonk.ocd", "Objects.ocd/Clonk.ocd/DefCore.txt", "Objects.ocd/Clonk.ocd/Script.c", "Scenario.txt"}
+	if len(paths) != len(want) {
+		t.Fatalf("WalkDir: got %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("WalkDir: got %v, want %v", paths, want)
+			break
+		}
+	}
+
+	fi, err := fs.Stat(fsys, "Objects.ocd/Clonk.ocd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.IsDir() || fi.Sys().(*Entry).Filename != "Clonk.ocd" {
+		t.Errorf("Clonk.ocd: unexpected file info %v", fi)
+	}
+	b, err := fs.ReadFile(fsys, "Objects.ocd/Clonk.ocd/Script.c")
+	if err != nil || string(b) != "#include Library_Clonk\n" {
+		t.Errorf("Script.c: got %q, %v", b, err)
+	}
+}
+
+func TestFSReadFileTruncated(t *testing.T) {
+	// A small packed group claiming a 1 GiB entry.
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&magicBytesWriter{w: &buf})
+	h := header{Ver1: C4GroupFileVer1, Ver2: C4GroupFileVer2, Entries: 1}
+	copy(h.ID[:], C4GroupFileID)
+	binary.Write(gz, binary.LittleEndian, &h)
+	e := entry{Size: 1 << 30}
+	copy(e.Filename[:], "Big.txt")
+	binary.Write(gz, binary.LittleEndian, &e)
+	gz.Write([]byte("data"))
+	gz.Close()
+	g, err := OpenReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err = NewFS(g).ReadFile("Big.txt")
+	runtime.ReadMemStats(&after)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected ErrUnexpectedEOF, got %v", err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<24 {
+		t.Errorf("allocated %d bytes for a truncated entry", alloc)
+	}
+}
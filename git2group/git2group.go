@@ -224,7 +224,7 @@ type ListGroupsEntry struct {
 	Hash string
 }
 
-var groupRegexp = regexp.MustCompile(`\.oc[dgfs]$`)
+var groupRegexp = regexp.MustCompile(`\.(oc|c4)[dgfs]$`)
 
 func isGroup(name string) bool {
 	return groupRegexp.MatchString(name)
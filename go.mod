@@ -1,3 +1,3 @@
 module github.com/lluchs/c4group-go
 
-go 1.12
+go 1.16
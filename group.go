@@ -0,0 +1,264 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrNotFound     error = errors.New("c4group: entry not found")
+	ErrEntryInvalid error = errors.New("c4group: entry exceeds group size")
+)
+
+// Archive is a group in any storage format: a packed group file or raw group
+// stream (Group) or an unpacked directory (DirGroup). Entries are addressed
+// with slash-separated paths like in Group.Lookup.
+type Archive interface {
+	// GroupHeader returns the group header.
+	GroupHeader() Header
+	// List returns the entries of the group in order.
+	List() []Entry
+	// OpenEntry opens the entry with the given name for reading. Child
+	// groups are returned as raw group stream, see NewRawReader.
+	OpenEntry(name string) (io.ReadCloser, error)
+	// OpenChild opens the child group with the given name. Callers must
+	// close the returned group.
+	OpenChild(name string) (Archive, error)
+	// Close releases the files of the group, e.g. of a packed child group
+	// of a directory.
+	Close() error
+}
+
+// Open opens the packed group file or unpacked group directory at path.
+func Open(path string) (Archive, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return OpenDir(path)
+	}
+	return openFile(path)
+}
+
+// openFile opens a packed group file. Closing the group closes the file.
+func openFile(filename string) (*Group, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	g, err := OpenReaderAt(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	g.closer = f
+	return g, nil
+}
+
+// Group provides random access to c4group archives. Unlike Reader, entries
+// can be opened in any order and any number of times. A Group is safe for
+// concurrent use.
+type Group struct {
+	Header  Header  // valid after OpenReaderAt
+	Entries []Entry // same
+
+	r          io.ReaderAt // uncompressed group data
+	size       int64
+	dataOffset int64 // offset of file data after all headers
+	entries    []entry
+
+	mu       sync.Mutex
+	children map[int]*Group // opened child groups by entry index
+
+	closer io.Closer // file opened by Open
+}
+
+// OpenReaderAt opens a packed c4group archive of the given size for random
+// access.
+//
+// Decompression state is saved in regular intervals while reading, so that
+// later accesses don't have to decompress the archive from the start.
+func OpenReaderAt(r io.ReaderAt, size int64) (*Group, error) {
+	pr, err := newPackedReaderAt(r, size)
+	if err != nil {
+		return nil, err
+	}
+	// The uncompressed size is unknown.
+	return openGroup(pr, math.MaxInt64)
+}
+
+// OpenRawReaderAt opens a raw, uncompressed group stream of the given size
+// for random access, e.g. the data of a child group.
+func OpenRawReaderAt(r io.ReaderAt, size int64) (*Group, error) {
+	return openGroup(r, size)
+}
+
+// openGroup reads the headers of an uncompressed group.
+func openGroup(r io.ReaderAt, size int64) (*Group, error) {
+	g := &Group{r: r, size: size}
+	var err error
+	g.entries, g.Entries, err = readHeaders(bufio.NewReader(io.NewSectionReader(r, 0, size)), &g.Header, size)
+	if err != nil {
+		return nil, err
+	}
+	g.dataOffset = HeaderSize + int64(len(g.entries))*EntrySize
+	for i := range g.entries {
+		e := &g.entries[i]
+		if e.Offset < 0 || e.Size < 0 || g.dataOffset+int64(e.Offset)+int64(e.Size) > size {
+			return nil, ErrEntryInvalid
+		}
+	}
+	return g, nil
+}
+
+// index returns the index of the entry with the given name.
+func (g *Group) index(name string) (int, error) {
+	for i := range g.Entries {
+		if g.Entries[i].Filename == name {
+			return i, nil
+		}
+	}
+	return -1, ErrNotFound
+}
+
+// child returns the child group at the given entry index.
+func (g *Group) child(i int) (*Group, error) {
+	if g.entries[i].ChildGroup == 0 {
+		return nil, ErrNoChildGroup
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if sub, ok := g.children[i]; ok {
+		return sub, nil
+	}
+	sub, err := openGroup(g.section(i), int64(g.entries[i].Size))
+	if err != nil {
+		return nil, err
+	}
+	if g.children == nil {
+		g.children = make(map[int]*Group)
+	}
+	g.children[i] = sub
+	return sub, nil
+}
+
+// section returns the data of the entry at the given index.
+func (g *Group) section(i int) *io.SectionReader {
+	e := &g.entries[i]
+	return io.NewSectionReader(g.r, g.dataOffset+int64(e.Offset), int64(e.Size))
+}
+
+// lookup resolves a slash-separated path to the containing group and the
+// entry index.
+func (g *Group) lookup(name string) (*Group, int, error) {
+	parts := strings.Split(name, "/")
+	for _, dir := range parts[:len(parts)-1] {
+		i, err := g.index(dir)
+		if err != nil {
+			return nil, -1, err
+		}
+		if g, err = g.child(i); err != nil {
+			return nil, -1, err
+		}
+	}
+	i, err := g.index(parts[len(parts)-1])
+	if err != nil {
+		return nil, -1, err
+	}
+	return g, i, nil
+}
+
+// Lookup returns the entry with the given name. Entries of child groups are
+// addressed with slash-separated paths, e.g. "Objects.ocd/Clonk.ocd/DefCore.txt".
+func (g *Group) Lookup(name string) (*Entry, error) {
+	g, i, err := g.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return &g.Entries[i], nil
+}
+
+// Open opens the entry with the given name for reading. Opening a child
+// group returns its raw, uncompressed group data.
+func (g *Group) Open(name string) (*io.SectionReader, error) {
+	g, i, err := g.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return g.section(i), nil
+}
+
+// OpenGroup opens the child group with the given name. An empty name
+// returns the group itself.
+func (g *Group) OpenGroup(name string) (*Group, error) {
+	if name == "" {
+		return g, nil
+	}
+	g, i, err := g.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return g.child(i)
+}
+
+// GroupHeader implements Archive.
+func (g *Group) GroupHeader() Header {
+	return g.Header
+}
+
+// List implements Archive.
+func (g *Group) List() []Entry {
+	return g.Entries
+}
+
+// OpenEntry implements Archive.
+func (g *Group) OpenEntry(name string) (io.ReadCloser, error) {
+	r, err := g.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(r), nil
+}
+
+// OpenChild implements Archive.
+func (g *Group) OpenChild(name string) (Archive, error) {
+	sub, err := g.OpenGroup(name)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Close closes the file of a group opened with Open.
+func (g *Group) Close() error {
+	if g.closer != nil {
+		return g.closer.Close()
+	}
+	return nil
+}
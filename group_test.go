@@ -0,0 +1,294 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// testData generates compressible pseudo-random text.
+func testData(size int) []byte {
+	words := []string{"Clonk", "Objects", "Wipf", "Rock ", "Loam\n", "Flint", "Gold", "\t", "1234"}
+	rnd := rand.New(rand.NewSource(1))
+	var buf bytes.Buffer
+	for buf.Len() < size {
+		buf.WriteString(words[rnd.Intn(len(words))])
+		if rnd.Intn(10) == 0 {
+			buf.WriteByte(byte(rnd.Intn(256)))
+		}
+	}
+	return buf.Bytes()[:size]
+}
+
+// writeTestGroup writes a group with the following structure:
+//
+//	Scenario.txt
+//	Objects.ocd/Clonk.ocd/DefCore.txt
+//	Objects.ocd/Clonk.ocd/Script.c
+//	Big.txt
+func writeTestGroup(t *testing.T, big []byte) []byte {
+	var buf bytes.Buffer
+	files := []struct{ name, data string }{
+		{"DefCore.txt", "[DefCore]\nid=Clonk\n"},
+		{"Script.c", "#include Library_Clonk\n"},
+	}
+	clonkSize := HeaderSize + len(files)*EntrySize
+	for _, f := range files {
+		clonkSize += len(f.data)
+	}
+	objectsSize := HeaderSize + EntrySize + clonkSize
+	scenario := "[Head]\nTitle=Test\n"
+
+	cw := NewWriter(&buf)
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	check(cw.WriteHeader(&Header{Entries: 3, Author: "Test"}))
+	check(cw.WriteEntry(&Entry{Filename: "Scenario.txt", Size: len(scenario)}))
+	check(cw.WriteEntry(&Entry{Filename: "Objects.ocd", Size: objectsSize, IsGroup: true}))
+	check(cw.WriteEntry(&Entry{Filename: "Big.txt", Size: len(big)}))
+	_, err := io.WriteString(cw, scenario)
+	check(err)
+	objects, err := cw.CreateSubGroup(&Header{Entries: 1})
+	check(err)
+	check(objects.WriteEntry(&Entry{Filename: "Clonk.ocd", Size: clonkSize, IsGroup: true}))
+	clonk, err := objects.CreateSubGroup(&Header{Entries: int32(len(files))})
+	check(err)
+	for _, f := range files {
+		check(clonk.WriteEntry(&Entry{Filename: f.name, Size: len(f.data)}))
+	}
+	for _, f := range files {
+		_, err = io.WriteString(clonk, f.data)
+		check(err)
+	}
+	check(clonk.Close())
+	check(objects.Close())
+	_, err = cw.Write(big)
+	check(err)
+	check(cw.Close())
+	return buf.Bytes()
+}
+
+func readTestEntry(t *testing.T, g *Group, name string) []byte {
+	t.Helper()
+	r, err := g.Open(name)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return b
+}
+
+func TestGroupRandomAccess(t *testing.T) {
+	big := testData(3 << 20)
+	data := writeTestGroup(t, big)
+	g, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Header.Author != "Test" || len(g.Entries) != 3 {
+		t.Errorf("unexpected header: %+v", g.Header)
+	}
+
+	// Read backwards, starting with the end of the big file.
+	r, err := g.Open("Big.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 1000)
+	for _, off := range []int64{int64(len(big)) - 1000, 2 << 20, 5, 1<<20 + 17, 0} {
+		if _, err := r.ReadAt(b, off); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, big[off:off+1000]) {
+			t.Errorf("Big.txt: data mismatch at offset %d", off)
+		}
+	}
+	if got := readTestEntry(t, g, "Objects.ocd/Clonk.ocd/Script.c"); string(got) != "#include Library_Clonk\n" {
+		t.Errorf("Script.c: got %q", got)
+	}
+	if got := readTestEntry(t, g, "Scenario.txt"); string(got) != "[Head]\nTitle=Test\n" {
+		t.Errorf("Scenario.txt: got %q", got)
+	}
+	if got := readTestEntry(t, g, "Objects.ocd/Clonk.ocd/DefCore.txt"); string(got) != "[DefCore]\nid=Clonk\n" {
+		t.Errorf("DefCore.txt: got %q", got)
+	}
+	if !bytes.Equal(readTestEntry(t, g, "Big.txt"), big) {
+		t.Error("Big.txt: data mismatch")
+	}
+
+	clonk, err := g.OpenGroup("Objects.ocd/Clonk.ocd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clonk.Entries) != 2 || clonk.Entries[1].Filename != "Script.c" {
+		t.Errorf("unexpected entries in Clonk.ocd: %+v", clonk.Entries)
+	}
+	if _, err := g.Open("Objects.ocd/Wipf.ocd/DefCore.txt"); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := g.OpenGroup("Scenario.txt"); err != ErrNoChildGroup {
+		t.Errorf("expected ErrNoChildGroup, got %v", err)
+	}
+}
+
+func TestPackedReaderAt(t *testing.T) {
+	data := testData(3<<20 + 123)
+	for _, level := range []int{gzip.NoCompression, gzip.HuffmanOnly, gzip.BestSpeed, gzip.DefaultCompression, gzip.BestCompression} {
+		var buf bytes.Buffer
+		gz, _ := gzip.NewWriterLevel(&magicBytesWriter{w: &buf}, level)
+		gz.Name = "test"
+		gz.Write(data)
+		gz.Close()
+
+		pr, err := newPackedReaderAt(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 4096)
+		for _, off := range []int64{3 << 20, 0, 2<<20 - 5, 2<<20 + 5, 1 << 20, 3<<20 + 100} {
+			n, err := pr.ReadAt(b, off)
+			want := data[off:]
+			if len(want) > len(b) {
+				want = want[:len(b)]
+			} else if err != io.EOF {
+				t.Errorf("level %d: expected EOF at offset %d, got %v", level, off, err)
+			}
+			if len(want) == len(b) && err != nil {
+				t.Fatalf("level %d: %v", level, err)
+			}
+			if !bytes.Equal(b[:n], want) {
+				t.Errorf("level %d: data mismatch at offset %d", level, off)
+			}
+		}
+		if len(pr.points) < 2 {
+			t.Errorf("level %d: expected checkpoints, got %d", level, len(pr.points))
+		}
+	}
+}
+
+func TestHeaderVersions(t *testing.T) {
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Unix(1500000000, 0)
+	for _, hdr := range []Header{
+		{Entries: 1},
+		{Entries: 1, Ver1: 1, Ver2: 1},
+		{Entries: 1, Unscrambled: true},
+	} {
+		var buf bytes.Buffer
+		cw := NewWriter(&buf)
+		check(cw.WriteHeader(&hdr))
+		check(cw.WriteEntry(&Entry{Filename: "Script.c", Size: 2, Mtime: mtime, HasCRC: true, CRC: 42}))
+		_, err := cw.Write([]byte("{}"))
+		check(err)
+		check(cw.Close())
+
+		r, err := NewReader(&buf)
+		check(err)
+		wantVer2 := hdr.Ver2
+		if hdr.Ver1 == 0 {
+			wantVer2 = C4GroupFileVer2
+		}
+		if r.Header.Ver1 != 1 || r.Header.Ver2 != wantVer2 || r.Header.Unscrambled != hdr.Unscrambled {
+			t.Errorf("got version %d.%d, unscrambled %v, want 1.%d, %v", r.Header.Ver1, r.Header.Ver2, r.Header.Unscrambled, wantVer2, hdr.Unscrambled)
+		}
+		e := r.Entries[0]
+		if legacy := wantVer2 < C4GroupFileVer2; e.HasCRC == legacy || e.Mtime.Equal(mtime) == legacy {
+			t.Errorf("version 1.%d: got entry %+v", wantVer2, e)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).WriteHeader(&Header{Ver1: 1, Ver2: 3}); !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("writing version 1.3: expected ErrInvalidHeader, got %v", err)
+	}
+
+	// Version 2.0 written by hand
+	buf.Reset()
+	gz := gzip.NewWriter(&buf)
+	h := header{Ver1: 2}
+	copy(h.ID[:], C4GroupFileID)
+	binary.Write(gz, binary.LittleEndian, &h)
+	gz.Close()
+	data := buf.Bytes()
+	data[0], data[1] = C4GzMagic1, C4GzMagic2
+	_, err := NewReader(bytes.NewReader(data))
+	var herr *HeaderError
+	if !errors.As(err, &herr) || herr.Field != "Ver1" || herr.Value != "2" || !errors.Is(err, ErrInvalidHeader) {
+		t.Errorf("expected Ver1 HeaderError, got %v", err)
+	}
+}
+
+func TestPackedReaderAtChecksum(t *testing.T) {
+	data := testData(1<<20 + 7)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&magicBytesWriter{w: &buf})
+	gz.Write(data)
+	gz.Close()
+	packed := buf.Bytes()
+	// Corrupt the CRC32 in the trailer.
+	packed[len(packed)-8] ^= 0xff
+
+	pr, err := newPackedReaderAt(bytes.NewReader(packed), int64(len(packed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := make([]byte, 4096)
+	if _, err := pr.ReadAt(b, 0); err != nil {
+		t.Errorf("reading at start: %v", err)
+	}
+	if _, err := pr.ReadAt(b, int64(len(data)-100)); err != gzip.ErrChecksum {
+		t.Errorf("reading the end: expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestHeaderEntriesBound(t *testing.T) {
+	var buf bytes.Buffer
+	h := header{Ver1: C4GroupFileVer1, Ver2: C4GroupFileVer2, Entries: math.MaxInt32}
+	copy(h.ID[:], C4GroupFileID)
+	binary.Write(&buf, binary.LittleEndian, &h)
+	buf.Write(make([]byte, 10*EntrySize))
+	data := buf.Bytes()
+
+	_, err := OpenRawReaderAt(bytes.NewReader(data), int64(len(data)))
+	var herr *HeaderError
+	if !errors.As(err, &herr) || herr.Field != "Entries" {
+		t.Errorf("expected Entries HeaderError, got %v", err)
+	}
+	// The size of a stream is unknown, reading fails at its end.
+	if _, err := NewRawReader(bytes.NewReader(data)); err == nil {
+		t.Error("expected error for truncated entry headers")
+	}
+}
@@ -0,0 +1,553 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+// This file contains a small DEFLATE (RFC 1951) decoder. Unlike
+// compress/flate, it exposes the decoder state at block boundaries so that
+// decompression can later be resumed from the middle of the stream, similar
+// to zlib's zran.c example.
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+var errCorrupt error = errors.New("c4group: corrupt deflate stream")
+
+const (
+	windowSize = 1 << 15 // maximum distance of back references
+	maxMatch   = 258     // maximum length of back references
+	maxBits    = 15      // maximum length of a huffman code
+	fastBits   = 9       // huffman codes up to this length are decoded by table lookup
+	bufSize    = 4 * windowSize
+)
+
+var (
+	lengthBase  = [29]uint16{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+	lengthExtra = [29]uint8{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+	distBase    = [30]uint16{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577}
+	distExtra   = [30]uint8{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13}
+	// order of code length code lengths in a dynamic block header
+	clOrder = [19]uint8{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+)
+
+// huffman is a canonical huffman decoding table.
+type huffman struct {
+	count  [maxBits + 1]uint16   // number of codes of each length
+	symbol []uint16              // symbols ordered by code
+	fast   [1 << fastBits]uint16 // symbol<<4 | length for short codes, 0 if longer
+}
+
+// init builds the decoding table from a list of code lengths.
+func (h *huffman) init(lengths []uint8) error {
+	h.count = [maxBits + 1]uint16{}
+	for _, l := range lengths {
+		h.count[l]++
+	}
+	h.count[0] = 0
+	// Check for an over-subscribed code. Incomplete codes are allowed.
+	left := 1
+	for l := 1; l <= maxBits; l++ {
+		left <<= 1
+		left -= int(h.count[l])
+		if left < 0 {
+			return errCorrupt
+		}
+	}
+	var offs [maxBits + 2]uint16
+	for l := 1; l <= maxBits; l++ {
+		offs[l+1] = offs[l] + h.count[l]
+	}
+	h.symbol = h.symbol[:0]
+	for range lengths {
+		h.symbol = append(h.symbol, 0)
+	}
+	for sym, l := range lengths {
+		if l != 0 {
+			h.symbol[offs[l]] = uint16(sym)
+			offs[l]++
+		}
+	}
+	// Fill the lookup table with bit-reversed codes.
+	h.fast = [1 << fastBits]uint16{}
+	code, index := 0, 0
+	for l := 1; l <= fastBits; l++ {
+		for i := 0; i < int(h.count[l]); i++ {
+			rev := 0
+			for b := 0; b < l; b++ {
+				rev |= ((code >> uint(b)) & 1) << uint(l-1-b)
+			}
+			for j := rev; j < len(h.fast); j += 1 << uint(l) {
+				h.fast[j] = h.symbol[index]<<4 | uint16(l)
+			}
+			code++
+			index++
+		}
+		code <<= 1
+	}
+	return nil
+}
+
+var fixedLit, fixedDist huffman
+
+func init() {
+	var lengths [288]uint8
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	fixedLit.init(lengths[:])
+	for i := 0; i < 30; i++ {
+		lengths[i] = 5
+	}
+	fixedDist.init(lengths[:30])
+}
+
+// inflater decompresses a raw DEFLATE stream.
+type inflater struct {
+	r     *bufio.Reader
+	in    int64  // number of bytes read from r, including the starting offset
+	bits  uint64 // bit buffer
+	nbits uint   // number of valid bits in the bit buffer
+
+	buf  []byte // decompressed data, including the back reference window
+	rpos int    // position of the next unread byte in buf
+	out  int64  // total number of bytes decompressed into buf
+	crc  uint32 // CRC32 of all decompressed data, checked against the gzip trailer
+
+	final   bool // current block is the last one
+	eof     bool // last block is finished
+	inBlock bool
+	stored  int // remaining bytes of a stored block
+	lit     *huffman
+	dist    *huffman
+	dynLit  huffman
+	dynDist huffman
+	lengths [288 + 32]uint8
+	atBlock func(f *inflater) // called at each block boundary
+}
+
+// newInflater creates an inflater reading compressed data from r. If the
+// compressed stream does not start at a byte boundary, bitOffset specifies
+// the number of bits to skip in the first byte. dict is the previously
+// decompressed data (up to windowSize bytes) back references may refer to.
+// in and out are the initial positions in compressed and decompressed data,
+// crc is the CRC32 of the decompressed data before out.
+func newInflater(r io.Reader, in int64, bitOffset uint, out int64, crc uint32, dict []byte) (*inflater, error) {
+	f := &inflater{
+		r:   bufio.NewReader(r),
+		in:  in,
+		out: out,
+		crc: crc,
+		buf: make([]byte, 0, bufSize),
+	}
+	f.buf = append(f.buf, dict...)
+	f.rpos = len(f.buf)
+	if bitOffset > 0 {
+		if _, err := f.readBits(bitOffset); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// bitPos returns the position of the next unread bit in the compressed stream.
+func (f *inflater) bitPos() int64 {
+	return f.in*8 - int64(f.nbits)
+}
+
+// window returns the last windowSize bytes of decompressed data.
+func (f *inflater) window() []byte {
+	start := len(f.buf) - windowSize
+	if start < 0 {
+		start = 0
+	}
+	return f.buf[start:]
+}
+
+func (f *inflater) needBits(n uint) error {
+	for f.nbits < n {
+		b, err := f.r.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		f.in++
+		f.bits |= uint64(b) << f.nbits
+		f.nbits += 8
+	}
+	return nil
+}
+
+func (f *inflater) readBits(n uint) (int, error) {
+	if err := f.needBits(n); err != nil {
+		return 0, err
+	}
+	v := int(f.bits & (1<<n - 1))
+	f.bits >>= n
+	f.nbits -= n
+	return v, nil
+}
+
+// decode reads a single huffman-coded symbol.
+func (f *inflater) decode(h *huffman) (int, error) {
+	if f.nbits < fastBits {
+		// The stream may end shortly after the last symbol, so we can't
+		// insist on having all bits available.
+		for f.nbits < fastBits {
+			b, err := f.r.ReadByte()
+			if err != nil {
+				break
+			}
+			f.in++
+			f.bits |= uint64(b) << f.nbits
+			f.nbits += 8
+		}
+	}
+	if e := h.fast[f.bits&(1<<fastBits-1)]; e != 0 && uint(e&15) <= f.nbits {
+		f.bits >>= e & 15
+		f.nbits -= uint(e & 15)
+		return int(e >> 4), nil
+	}
+	// Slow path: decode bit by bit.
+	code, first, index := 0, 0, 0
+	for l := 1; l <= maxBits; l++ {
+		b, err := f.readBits(1)
+		if err != nil {
+			return 0, err
+		}
+		code |= b
+		count := int(h.count[l])
+		if code-count < first {
+			return int(h.symbol[index+code-first]), nil
+		}
+		index += count
+		first += count
+		first <<= 1
+		code <<= 1
+	}
+	return 0, errCorrupt
+}
+
+// nextBlock reads the header of the next block.
+func (f *inflater) nextBlock() error {
+	if f.atBlock != nil {
+		f.atBlock(f)
+	}
+	hdr, err := f.readBits(3)
+	if err != nil {
+		return err
+	}
+	f.final = hdr&1 == 1
+	switch hdr >> 1 {
+	case 0:
+		// Stored block: skip to byte boundary.
+		f.bits >>= f.nbits % 8
+		f.nbits -= f.nbits % 8
+		n, err := f.readBits(16)
+		if err != nil {
+			return err
+		}
+		nn, err := f.readBits(16)
+		if err != nil {
+			return err
+		}
+		if n != ^nn&0xffff {
+			return errCorrupt
+		}
+		f.stored = n
+		f.lit = nil
+	case 1:
+		f.lit, f.dist = &fixedLit, &fixedDist
+	case 2:
+		if err := f.readDynamic(); err != nil {
+			return err
+		}
+		f.lit, f.dist = &f.dynLit, &f.dynDist
+	default:
+		return errCorrupt
+	}
+	f.inBlock = true
+	return nil
+}
+
+// readDynamic reads the code tables of a dynamic huffman block.
+func (f *inflater) readDynamic() error {
+	nlen, err := f.readBits(5)
+	if err != nil {
+		return err
+	}
+	ndist, err := f.readBits(5)
+	if err != nil {
+		return err
+	}
+	ncode, err := f.readBits(4)
+	if err != nil {
+		return err
+	}
+	nlen += 257
+	ndist++
+	ncode += 4
+	if nlen > 286 || ndist > 30 {
+		return errCorrupt
+	}
+	var clLengths [19]uint8
+	for i := 0; i < ncode; i++ {
+		v, err := f.readBits(3)
+		if err != nil {
+			return err
+		}
+		clLengths[clOrder[i]] = uint8(v)
+	}
+	var cl huffman
+	if err := cl.init(clLengths[:]); err != nil {
+		return err
+	}
+	lengths := f.lengths[:nlen+ndist]
+	for i := 0; i < len(lengths); {
+		sym, err := f.decode(&cl)
+		if err != nil {
+			return err
+		}
+		if sym < 16 {
+			lengths[i] = uint8(sym)
+			i++
+			continue
+		}
+		var rep int
+		var val uint8
+		switch sym {
+		case 16:
+			if i == 0 {
+				return errCorrupt
+			}
+			val = lengths[i-1]
+			rep, err = f.readBits(2)
+			rep += 3
+		case 17:
+			rep, err = f.readBits(3)
+			rep += 3
+		default:
+			rep, err = f.readBits(7)
+			rep += 11
+		}
+		if err != nil {
+			return err
+		}
+		if i+rep > len(lengths) {
+			return errCorrupt
+		}
+		for ; rep > 0; rep-- {
+			lengths[i] = val
+			i++
+		}
+	}
+	if lengths[256] == 0 {
+		return errCorrupt
+	}
+	if err := f.dynLit.init(lengths[:nlen]); err != nil {
+		return err
+	}
+	return f.dynDist.init(lengths[nlen:])
+}
+
+// step decompresses more data into buf. Returns io.EOF after the last block.
+// After the last block, the gzip trailer following it is verified.
+func (f *inflater) step() error {
+	if f.eof {
+		return io.EOF
+	}
+	// Drop data which has been read and is outside of the window.
+	drop := len(f.buf) - windowSize
+	if drop > f.rpos {
+		drop = f.rpos
+	}
+	if drop > 0 {
+		copy(f.buf, f.buf[drop:])
+		f.buf = f.buf[:len(f.buf)-drop]
+		f.rpos -= drop
+	}
+	start := len(f.buf)
+	err := f.inflate()
+	f.crc = crc32.Update(f.crc, crc32.IEEETable, f.buf[start:])
+	if err == nil && f.eof {
+		err = f.checkTrailer()
+	}
+	return err
+}
+
+// checkTrailer reads the gzip trailer after the last block and compares it
+// with the decompressed data.
+func (f *inflater) checkTrailer() error {
+	// The trailer starts at the next byte boundary.
+	f.bits >>= f.nbits % 8
+	f.nbits -= f.nbits % 8
+	crc, err := f.readBits(32)
+	if err != nil {
+		return err
+	}
+	size, err := f.readBits(32)
+	if err != nil {
+		return err
+	}
+	if uint32(crc) != f.crc || uint32(size) != uint32(f.out) {
+		return gzip.ErrChecksum
+	}
+	return nil
+}
+
+// inflate decompresses the next part of the current block.
+func (f *inflater) inflate() error {
+	if !f.inBlock {
+		if err := f.nextBlock(); err != nil {
+			return err
+		}
+	}
+	if f.lit == nil {
+		return f.stepStored()
+	}
+	for len(f.buf) < cap(f.buf)-maxMatch {
+		sym, err := f.decode(f.lit)
+		if err != nil {
+			return err
+		}
+		if sym < 256 {
+			f.buf = append(f.buf, byte(sym))
+			f.out++
+			continue
+		}
+		if sym == 256 {
+			f.endBlock()
+			return nil
+		}
+		sym -= 257
+		if sym >= len(lengthBase) {
+			return errCorrupt
+		}
+		length, err := f.readBits(uint(lengthExtra[sym]))
+		if err != nil {
+			return err
+		}
+		length += int(lengthBase[sym])
+		dsym, err := f.decode(f.dist)
+		if err != nil {
+			return err
+		}
+		if dsym >= len(distBase) {
+			return errCorrupt
+		}
+		dist, err := f.readBits(uint(distExtra[dsym]))
+		if err != nil {
+			return err
+		}
+		dist += int(distBase[dsym])
+		if dist > len(f.buf) {
+			return errCorrupt
+		}
+		// Copy byte by byte, the source may overlap the destination.
+		pos := len(f.buf) - dist
+		for i := 0; i < length; i++ {
+			f.buf = append(f.buf, f.buf[pos+i])
+		}
+		f.out += int64(length)
+	}
+	return nil
+}
+
+// stepStored copies data from a stored block.
+func (f *inflater) stepStored() error {
+	for f.stored > 0 && len(f.buf) < cap(f.buf) {
+		var b byte
+		if f.nbits >= 8 {
+			b = byte(f.bits)
+			f.bits >>= 8
+			f.nbits -= 8
+		} else {
+			var err error
+			if b, err = f.r.ReadByte(); err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			f.in++
+		}
+		f.buf = append(f.buf, b)
+		f.out++
+		f.stored--
+	}
+	if f.stored == 0 {
+		f.endBlock()
+	}
+	return nil
+}
+
+func (f *inflater) endBlock() {
+	f.inBlock = false
+	if f.final {
+		f.eof = true
+	}
+}
+
+// Read reads decompressed data.
+func (f *inflater) Read(b []byte) (int, error) {
+	for f.rpos == len(f.buf) {
+		if err := f.step(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(b, f.buf[f.rpos:])
+	f.rpos += n
+	return n, nil
+}
+
+// pos returns the offset of the next byte returned by Read in decompressed
+// data.
+func (f *inflater) pos() int64 {
+	return f.out - int64(len(f.buf)-f.rpos)
+}
+
+// discard skips n bytes of decompressed data. Returns io.EOF if the stream
+// ends before.
+func (f *inflater) discard(n int64) error {
+	for n > 0 {
+		if f.rpos == len(f.buf) {
+			if err := f.step(); err != nil {
+				return err
+			}
+			continue
+		}
+		skip := len(f.buf) - f.rpos
+		if int64(skip) > n {
+			skip = int(n)
+		}
+		f.rpos += skip
+		n -= int64(skip)
+	}
+	return nil
+}
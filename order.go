@@ -0,0 +1,142 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+)
+
+// Sort copies the group src to dst, ordering the entries of all groups with
+// NameLess: the root group by name, each child group by its own filename.
+// name is needed as groups don't store their own filename. Headers,
+// timestamps and checksums are kept. As entries can only be written after
+// reading the whole stream, src is buffered in memory. Use Editor.SortAll
+// for group files instead.
+func Sort(dst io.Writer, name string, src *Reader) error {
+	b, err := readBuilder(name, src)
+	if err != nil {
+		return err
+	}
+	b.Sort()
+	return b.Pack(dst)
+}
+
+// readBuilder reads all entries of r into a Builder.
+func readBuilder(name string, r *Reader) (*Builder, error) {
+	b := NewBuilder(name)
+	b.Header = r.Header
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if e.IsGroup {
+			sub, err := r.ReadGroup()
+			if err != nil {
+				return nil, err
+			}
+			child, err := readBuilder(e.Filename, sub)
+			if err != nil {
+				return nil, err
+			}
+			b.entries = append(b.entries, &builderEntry{Entry: *e, group: child})
+			continue
+		}
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		b.AddFile(*e, bytesFile(data))
+	}
+}
+
+// CheckOrder returns the slash-separated paths of the entries in r which are
+// out of order according to NameLess. Of each group, the fewest entries are
+// reported whose removal leaves the others in order. name is the filename of
+// the group.
+func CheckOrder(name string, r *Reader) ([]string, error) {
+	return checkOrder(name, "", r)
+}
+
+func checkOrder(name, dir string, r *Reader) ([]string, error) {
+	var names []string
+	var children [][]string // misplaced entries of child groups
+	for {
+		e, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var m []string
+		if e.IsGroup {
+			sub, err := r.ReadGroup()
+			if err != nil {
+				return nil, err
+			}
+			m, err = checkOrder(e.Filename, path.Join(dir, e.Filename), sub)
+			if err != nil {
+				return nil, err
+			}
+		}
+		names = append(names, e.Filename)
+		children = append(children, m)
+	}
+	inOrder := longestOrdered(names, NameLess(name))
+	var misplaced []string
+	for i, name := range names {
+		if !inOrder[i] {
+			misplaced = append(misplaced, path.Join(dir, name))
+		}
+		misplaced = append(misplaced, children[i]...)
+	}
+	return misplaced, nil
+}
+
+// longestOrdered marks the names of a longest subsequence which is ordered by
+// less. Like commonOrder, it uses patience sorting.
+func longestOrdered(names []string, less func(a, b string) bool) []bool {
+	// tails[k] is the index of the smallest last name of an ordered
+	// subsequence of length k+1. prev links each name to its predecessor in
+	// such a subsequence.
+	var tails []int
+	prev := make([]int, len(names))
+	for i, name := range names {
+		k := sort.Search(len(tails), func(k int) bool { return less(name, names[tails[k]]) })
+		prev[i] = -1
+		if k > 0 {
+			prev[i] = tails[k-1]
+		}
+		if k == len(tails) {
+			tails = append(tails, i)
+		} else {
+			tails[k] = i
+		}
+	}
+	inOrder := make([]bool, len(names))
+	if len(tails) > 0 {
+		for i := tails[len(tails)-1]; i >= 0; i = prev[i] {
+			inOrder[i] = true
+		}
+	}
+	return inOrder
+}
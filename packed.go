@@ -0,0 +1,178 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+)
+
+var ErrUnsupportedCompression error = errors.New("c4group: unsupported compression method")
+
+// Distance between decompression checkpoints in decompressed bytes. Each
+// checkpoint holds a copy of the 32 KiB deflate window.
+const checkpointSpan = 1 << 20
+
+// gzip header flags
+const (
+	gzipFlagHCRC    = 1 << 1
+	gzipFlagExtra   = 1 << 2
+	gzipFlagName    = 1 << 3
+	gzipFlagComment = 1 << 4
+)
+
+// checkpoint allows resuming decompression at a deflate block boundary.
+type checkpoint struct {
+	bitPos int64  // position in compressed data in bits
+	out    int64  // position in decompressed data
+	crc    uint32 // CRC32 of the decompressed data preceding out
+	window []byte // decompressed data preceding out
+}
+
+// packedReaderAt provides random access to the decompressed contents of a
+// packed group file. The checkpoint index is built incrementally while
+// decompressing.
+type packedReaderAt struct {
+	mu     sync.Mutex
+	r      io.ReaderAt
+	size   int64
+	points []checkpoint
+	f      *inflater // most recently used decompressor
+}
+
+func newPackedReaderAt(r io.ReaderAt, size int64) (*packedReaderAt, error) {
+	start, err := gzipDataOffset(bufio.NewReader(io.NewSectionReader(r, 0, size)))
+	if err != nil {
+		return nil, err
+	}
+	return &packedReaderAt{
+		r:      r,
+		size:   size,
+		points: []checkpoint{{bitPos: start * 8}},
+	}, nil
+}
+
+// gzipDataOffset reads the (c4group-modified) gzip header and returns the
+// offset of the compressed data.
+func gzipDataOffset(r *bufio.Reader) (int64, error) {
+	var hdr [10]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, err
+	}
+	if hdr[0] != C4GzMagic1 || hdr[1] != C4GzMagic2 {
+		return 0, ErrInvalidMagic
+	}
+	if hdr[2] != 8 {
+		return 0, ErrUnsupportedCompression
+	}
+	flags := hdr[3]
+	offset := int64(len(hdr))
+	if flags&gzipFlagExtra != 0 {
+		var xlen [2]byte
+		if _, err := io.ReadFull(r, xlen[:]); err != nil {
+			return 0, err
+		}
+		n := int64(xlen[0]) | int64(xlen[1])<<8
+		if _, err := r.Discard(int(n)); err != nil {
+			return 0, err
+		}
+		offset += 2 + n
+	}
+	for _, flag := range []byte{gzipFlagName, gzipFlagComment} {
+		if flags&flag != 0 {
+			s, err := r.ReadBytes(0)
+			if err != nil {
+				return 0, err
+			}
+			offset += int64(len(s))
+		}
+	}
+	if flags&gzipFlagHCRC != 0 {
+		offset += 2
+	}
+	return offset, nil
+}
+
+// ReadAt implements io.ReaderAt for the decompressed data.
+func (p *packedReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("c4group: negative offset")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Find the closest checkpoint before off. Continue with the previous
+	// decompressor instead if it is closer.
+	i := sort.Search(len(p.points), func(i int) bool { return p.points[i].out > off }) - 1
+	cp := &p.points[i]
+	f := p.f
+	if f == nil || f.pos() > off || f.pos() < cp.out {
+		var err error
+		if f, err = p.resume(cp); err != nil {
+			return 0, err
+		}
+		p.f = f
+	}
+	if err := f.discard(off - f.pos()); err != nil {
+		if err != io.EOF {
+			p.f = nil
+		}
+		return 0, err
+	}
+	n, err := io.ReadFull(f, b)
+	switch err {
+	case nil:
+	case io.ErrUnexpectedEOF:
+		if f.eof {
+			err = io.EOF
+		}
+		fallthrough
+	default:
+		if err != io.EOF {
+			p.f = nil
+		}
+	}
+	return n, err
+}
+
+// resume creates a new decompressor starting at the given checkpoint.
+func (p *packedReaderAt) resume(cp *checkpoint) (*inflater, error) {
+	in := cp.bitPos / 8
+	f, err := newInflater(io.NewSectionReader(p.r, in, p.size-in), in, uint(cp.bitPos%8), cp.out, cp.crc, cp.window)
+	if err != nil {
+		return nil, err
+	}
+	f.atBlock = p.addCheckpoint
+	return f, nil
+}
+
+// addCheckpoint extends the checkpoint index if f is past the last
+// checkpoint.
+func (p *packedReaderAt) addCheckpoint(f *inflater) {
+	last := &p.points[len(p.points)-1]
+	if f.out-last.out < checkpointSpan {
+		return
+	}
+	window := f.window()
+	p.points = append(p.points, checkpoint{
+		bitPos: f.bitPos(),
+		out:    f.out,
+		crc:    f.crc,
+		window: append([]byte(nil), window...),
+	})
+}
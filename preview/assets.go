@@ -0,0 +1,211 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lluchs/c4group-go"
+	"github.com/lluchs/c4group-go/core"
+)
+
+// AssetKind identifies a display image.
+type AssetKind string
+
+const (
+	Icon    AssetKind = "icon"    // scenario and folder icon
+	Title   AssetKind = "title"   // scenario and folder title image
+	Picture AssetKind = "picture" // definition picture from its graphics
+	BigIcon AssetKind = "bigicon" // large definition or player icon
+)
+
+// Asset is a display image of a group.
+type Asset struct {
+	Kind  AssetKind
+	File  string // source file in the group
+	Image image.Image
+}
+
+// Candidates for scenario images in the order the engine tries them.
+var (
+	iconFiles  = []string{c4group.C4CFN_IconPNG, c4group.C4CFN_ScenarioIcon}
+	titleFiles = []string{
+		c4group.C4CFN_ScenarioTitle + ".png",
+		c4group.C4CFN_ScenarioTitle + ".jpg",
+		c4group.C4CFN_ScenarioTitle + ".jpeg",
+		c4group.C4CFN_ScenarioTitle + ".bmp",
+	}
+)
+
+// Assets extracts the images a launcher shows for a group, depending on the
+// extension of its filename:
+//
+//   - scenarios (.ocs) and folders (.ocf): Icon.png or Icon.bmp and the
+//     title image Title.png, Title.jpg or Title.bmp
+//   - definitions (.ocd): Graphics.png cropped to the DefCore Picture rect
+//     and BigIcon.png
+//   - players (.ocp): BigIcon.png
+//
+// Missing images are skipped. Bitmaps are decoded so that the images can be
+// stored as PNG.
+func Assets(group fs.FS, filename string) ([]Asset, error) {
+	var assets []Asset
+	add := func(kind AssetKind, candidates ...string) error {
+		for _, name := range candidates {
+			img, err := readImage(group, name)
+			if err != nil {
+				return err
+			}
+			if img != nil {
+				assets = append(assets, Asset{Kind: kind, File: name, Image: img})
+				return nil
+			}
+		}
+		return nil
+	}
+	var err error
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".ocs", ".ocf":
+		if err = add(Icon, iconFiles...); err == nil {
+			err = add(Title, titleFiles...)
+		}
+	case ".ocd":
+		var picture *Asset
+		if picture, err = definitionPicture(group); err == nil {
+			if picture != nil {
+				assets = append(assets, *picture)
+			}
+			err = add(BigIcon, c4group.C4CFN_BigIcon)
+		}
+	case ".ocp":
+		err = add(BigIcon, c4group.C4CFN_BigIcon)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// definitionPicture crops the Picture rect from the definition's graphics.
+// High-resolution graphics (Graphics.<scale>.png) are used if there is no
+// Graphics.png, with the rect scaled accordingly.
+func definitionPicture(group fs.FS) (*Asset, error) {
+	file, scale, err := findGraphics(group)
+	if err != nil || file == "" {
+		return nil, err
+	}
+	img, err := readImage(group, file)
+	if err != nil || img == nil {
+		return nil, err
+	}
+
+	var defCore struct {
+		DefCore struct {
+			Width, Height int
+			Picture       []int
+		}
+	}
+	data, err := fs.ReadFile(group, c4group.C4CFN_DefCore)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if _, err := core.Decode(bytes.NewReader(data), &defCore); err != nil {
+		return nil, &fs.PathError{Op: "decode", Path: c4group.C4CFN_DefCore, Err: err}
+	}
+	d := defCore.DefCore
+	var rect image.Rectangle
+	switch {
+	case len(d.Picture) >= 4:
+		rect = image.Rect(d.Picture[0], d.Picture[1], d.Picture[0]+d.Picture[2], d.Picture[1]+d.Picture[3])
+	case d.Width > 0 && d.Height > 0:
+		rect = image.Rect(0, 0, d.Width, d.Height)
+	default:
+		rect = image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+		scale = 1
+	}
+	rect = image.Rect(rect.Min.X*scale, rect.Min.Y*scale, rect.Max.X*scale, rect.Max.Y*scale).
+		Add(img.Bounds().Min).Intersect(img.Bounds())
+	if rect.Empty() {
+		return nil, nil
+	}
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		img = sub.SubImage(rect)
+	}
+	return &Asset{Kind: Picture, File: file, Image: img}, nil
+}
+
+// findGraphics returns Graphics.png or the scaled graphics with the lowest
+// scale.
+func findGraphics(group fs.FS) (file string, scale int, err error) {
+	if _, err := fs.Stat(group, c4group.C4CFN_DefGraphics); err == nil {
+		return c4group.C4CFN_DefGraphics, 1, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", 0, err
+	}
+	entries, err := fs.ReadDir(group, ".")
+	if err != nil {
+		return "", 0, err
+	}
+	type scaled struct {
+		file  string
+		scale int
+	}
+	var candidates []scaled
+	for _, e := range entries {
+		name := e.Name()
+		if !c4group.WildcardMatch(c4group.C4CFN_DefGraphicsScaled, name) {
+			continue
+		}
+		s, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "Graphics."), ".png"))
+		if err == nil && s > 0 {
+			candidates = append(candidates, scaled{name, s})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", 0, nil
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].scale < candidates[j].scale })
+	return candidates[0].file, candidates[0].scale, nil
+}
+
+// readImage decodes the named PNG, JPEG or BMP file, returning nil if it
+// doesn't exist.
+func readImage(group fs.FS, name string) (image.Image, error) {
+	data, err := fs.ReadFile(group, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var img image.Image
+	if strings.EqualFold(path.Ext(name), ".bmp") {
+		img, err = decodeBMP(bytes.NewReader(data))
+	} else {
+		img, _, err = image.Decode(bytes.NewReader(data))
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "decode", Path: name, Err: err}
+	}
+	return img, nil
+}
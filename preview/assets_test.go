@@ -0,0 +1,105 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+package preview
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+	"testing/fstest"
+)
+
+// encodeSplitPNG creates an image with a red left and a blue right half.
+func encodeSplitPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{0xff, 0, 0, 0xff}
+			if x >= width/2 {
+				c = color.RGBA{0, 0, 0xff, 0xff}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestAssets(t *testing.T) {
+	kinds := func(assets []Asset) map[AssetKind]Asset {
+		m := make(map[AssetKind]Asset)
+		for _, a := range assets {
+			m[a.Kind] = a
+		}
+		return m
+	}
+
+	scenario := fstest.MapFS{
+		"Icon.bmp":  {Data: encodeBMP(t, 2, []byte{1, 2}, []byte{3, 4})},
+		"Title.jpg": {Data: []byte("not used")},
+		"Title.png": {Data: encodePNG(t, color.White)},
+	}
+	assets, err := Assets(scenario, "Test.ocs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := kinds(assets)
+	if len(assets) != 2 || m[Icon].File != "Icon.bmp" || m[Title].File != "Title.png" {
+		t.Errorf("scenario: unexpected assets %+v", assets)
+	}
+
+	def := fstest.MapFS{
+		"DefCore.txt":  {Data: []byte("[DefCore]\nid=Test\nWidth=4\nHeight=4\nPicture=4,0,4,4\n")},
+		"Graphics.png": {Data: encodeSplitPNG(t, 8, 4)},
+		"BigIcon.png":  {Data: encodePNG(t, color.White)},
+	}
+	scaledDef := fstest.MapFS{
+		"DefCore.txt":    def["DefCore.txt"],
+		"Graphics.2.png": {Data: encodeSplitPNG(t, 16, 8)},
+	}
+	for name, fsys := range map[string]fstest.MapFS{"Test.ocd": def, "Scaled.ocd": scaledDef} {
+		assets, err := Assets(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		picture, ok := kinds(assets)[Picture]
+		if !ok {
+			t.Errorf("%s: no picture in %+v", name, assets)
+			continue
+		}
+		size := picture.Image.Bounds().Size()
+		if name == "Scaled.ocd" && size != image.Pt(8, 8) || name == "Test.ocd" && size != image.Pt(4, 4) {
+			t.Errorf("%s: unexpected picture size %v", name, size)
+		}
+		b := picture.Image.Bounds()
+		if r, g, bl, _ := picture.Image.At(b.Min.X, b.Min.Y).RGBA(); r != 0 || g != 0 || bl != 0xffff {
+			t.Errorf("%s: picture not cropped to the blue half", name)
+		}
+	}
+
+	player := fstest.MapFS{"BigIcon.png": {Data: encodePNG(t, color.White)}}
+	assets, err = Assets(player, "Me.ocp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assets) != 1 || assets[0].Kind != BigIcon {
+		t.Errorf("player: unexpected assets %+v", assets)
+	}
+}
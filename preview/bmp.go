@@ -0,0 +1,184 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+// Package preview renders map images of scenarios and extracts the icons and
+// pictures which the game shows for groups.
+//
+// Scenario maps are 8-bit palettized bitmaps (Map.bmp, Landscape.bmp and
+// their Fg/Bg variants) whose color indices refer to material-texture pairs
+// in TexMap.txt. The preview colors each index with the average color of its
+// texture from Material.ocg.
+package preview
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+	"math"
+)
+
+var ErrInvalidBMP error = errors.New("preview: invalid or unsupported bitmap")
+
+// Limits of bitmap dimensions, well above the largest engine maps.
+const (
+	maxBMPSide   = 1 << 15
+	maxBMPPixels = 1 << 26
+)
+
+// DecodeBMP decodes an uncompressed 8-bit palettized bitmap as written by the
+// engine and common image editors. The pixel values are the color indices.
+func DecodeBMP(r io.Reader) (*image.Paletted, error) {
+	img, err := decodeBMP(r)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := img.(*image.Paletted)
+	if !ok {
+		return nil, ErrInvalidBMP
+	}
+	return p, nil
+}
+
+// decodeBMP decodes uncompressed 8, 24 or 32-bit bitmaps.
+func decodeBMP(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+	var fileHeader struct {
+		Magic    [2]byte
+		Size     uint32
+		Reserved uint32
+		Offset   uint32
+	}
+	if err := binary.Read(br, binary.LittleEndian, &fileHeader); err != nil {
+		return nil, err
+	}
+	if fileHeader.Magic != [2]byte{'B', 'M'} {
+		return nil, ErrInvalidBMP
+	}
+	var info struct {
+		Size          uint32
+		Width, Height int32
+		Planes        uint16
+		BitCount      uint16
+		Compression   uint32
+		SizeImage     uint32
+		XPelsPerMeter int32
+		YPelsPerMeter int32
+		ClrUsed       uint32
+		ClrImportant  uint32
+	}
+	if err := binary.Read(br, binary.LittleEndian, &info); err != nil {
+		return nil, err
+	}
+	const fileHeaderSize, infoHeaderSize = 14, 40
+	const biRGB, biBitfields = 0, 3
+	validFormat := (info.BitCount == 8 || info.BitCount == 24) && info.Compression == biRGB ||
+		info.BitCount == 32 && (info.Compression == biRGB || info.Compression == biBitfields)
+	if info.Size < infoHeaderSize || !validFormat || info.Width <= 0 || info.Height == 0 || info.Height == math.MinInt32 {
+		return nil, ErrInvalidBMP
+	}
+	// The image is allocated before reading any pixels.
+	w, h := int64(info.Width), int64(info.Height)
+	if h < 0 {
+		h = -h
+	}
+	if w > maxBMPSide || h > maxBMPSide || w*h > maxBMPPixels {
+		return nil, ErrInvalidBMP
+	}
+	if _, err := br.Discard(int(info.Size) - infoHeaderSize); err != nil {
+		return nil, err
+	}
+	read := fileHeaderSize + int(info.Size)
+	if info.Compression == biBitfields && info.Size == infoHeaderSize {
+		// Color masks follow the header. Assume the usual BGRA order.
+		if _, err := br.Discard(12); err != nil {
+			return nil, err
+		}
+		read += 12
+	}
+
+	var palette color.Palette
+	if info.BitCount == 8 {
+		colors := int(info.ClrUsed)
+		if colors == 0 || colors > 256 {
+			colors = 256
+		}
+		if read+4*colors > int(fileHeader.Offset) {
+			// Some writers store fewer colors than announced.
+			colors = (int(fileHeader.Offset) - read) / 4
+			if colors < 0 {
+				return nil, ErrInvalidBMP
+			}
+		}
+		palette = make(color.Palette, 256)
+		for i := range palette {
+			palette[i] = color.RGBA{A: 0xff}
+		}
+		var bgrx [4]byte
+		for i := 0; i < colors; i++ {
+			if _, err := io.ReadFull(br, bgrx[:]); err != nil {
+				return nil, err
+			}
+			palette[i] = color.RGBA{bgrx[2], bgrx[1], bgrx[0], 0xff}
+		}
+		read += 4 * colors
+	}
+	if int(fileHeader.Offset) < read {
+		return nil, ErrInvalidBMP
+	}
+	if _, err := br.Discard(int(fileHeader.Offset) - read); err != nil {
+		return nil, err
+	}
+
+	width, height := int(info.Width), int(info.Height)
+	topDown := height < 0
+	if topDown {
+		height = -height
+	}
+	bytesPerPixel := int(info.BitCount) / 8
+	row := make([]byte, (width*bytesPerPixel+3)&^3)
+	var img image.Image
+	var setRow func(y int)
+	switch bytesPerPixel {
+	case 1:
+		p := image.NewPaletted(image.Rect(0, 0, width, height), palette)
+		img = p
+		setRow = func(y int) { copy(p.Pix[y*p.Stride:], row[:width]) }
+	default:
+		rgba := image.NewNRGBA(image.Rect(0, 0, width, height))
+		img = rgba
+		setRow = func(y int) {
+			for x := 0; x < width; x++ {
+				px := row[x*bytesPerPixel:]
+				a := byte(0xff)
+				if bytesPerPixel == 4 && info.Compression == biBitfields {
+					a = px[3]
+				}
+				rgba.SetNRGBA(x, y, color.NRGBA{px[2], px[1], px[0], a})
+			}
+		}
+	}
+	for i := 0; i < height; i++ {
+		if _, err := io.ReadFull(br, row); err != nil {
+			return nil, err
+		}
+		y := height - 1 - i
+		if topDown {
+			y = i
+		}
+		setRow(y)
+	}
+	return img, nil
+}
@@ -0,0 +1,300 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+package preview
+
+import (
+	"bytes"
+	"errors"
+	"hash/fnv"
+	"image"
+	"image/color"
+	_ "image/jpeg" // textures
+	_ "image/png"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/lluchs/c4group-go"
+)
+
+var ErrNoMap error = errors.New("preview: scenario has no map bitmap")
+
+// Options configures Render.
+type Options struct {
+	// Materials is the system Material.ocg which provides materials and
+	// textures the scenario doesn't define itself. May be nil.
+	Materials fs.FS
+	// Width limits the width of the preview. The map size is kept if it
+	// is 0.
+	Width int
+}
+
+// mapFiles lists foreground and background bitmaps in order of preference.
+var mapFiles = [][2]string{
+	{c4group.C4CFN_LandscapeFg, c4group.C4CFN_LandscapeBg},
+	{c4group.C4CFN_Landscape, ""},
+	{c4group.C4CFN_MapFg, c4group.C4CFN_MapBg},
+	{c4group.C4CFN_Map, ""},
+}
+
+// Render draws a preview of the scenario's map. Landscape bitmaps are
+// preferred over Map bitmaps. Sky is transparent and background materials
+// are darkened. In legacy single-layer bitmaps, the IFT bit 0x80 marks
+// tunnel background. Scenarios with dynamic maps (Map.c) have no bitmap and
+// result in ErrNoMap. opts may be nil.
+func Render(scenario fs.FS, opts *Options) (image.Image, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var fg, bg *image.Paletted
+	for _, files := range mapFiles {
+		var err error
+		if fg, err = readBMP(scenario, files[0]); err != nil {
+			return nil, err
+		}
+		if fg == nil {
+			continue
+		}
+		if files[1] != "" {
+			if bg, err = readBMP(scenario, files[1]); err != nil {
+				return nil, err
+			}
+		}
+		break
+	}
+	if fg == nil {
+		return nil, ErrNoMap
+	}
+
+	// Scenario materials take precedence over the system ones.
+	var sources []fs.FS
+	if fi, err := fs.Stat(scenario, c4group.C4CFN_Material); err == nil && fi.IsDir() {
+		sub, err := fs.Sub(scenario, c4group.C4CFN_Material)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, sub)
+	}
+	if opts.Materials != nil {
+		sources = append(sources, opts.Materials)
+	}
+	texMap, err := loadTexMap(scenario, sources)
+	if err != nil {
+		return nil, err
+	}
+	palette, err := colors(texMap, sources)
+	if err != nil {
+		return nil, err
+	}
+
+	tunnel := tunnelColor(texMap, palette)
+	bounds := fg.Bounds()
+	img := image.NewRGBA(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			var c color.RGBA
+			i := fg.ColorIndexAt(x, y)
+			if bg == nil {
+				// Single-layer bitmaps mark pixels in front of tunnel
+				// background with the IFT bit.
+				if i&iftMask != 0 {
+					c = darken(tunnel)
+				}
+				i &^= iftMask
+			}
+			if i != 0 {
+				c = palette[i]
+			} else if bg != nil && image.Pt(x, y).In(bg.Bounds()) && bg.ColorIndexAt(x, y) != 0 {
+				c = darken(palette[bg.ColorIndexAt(x, y)])
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	if opts.Width > 0 && opts.Width < bounds.Dx() {
+		return scale(img, opts.Width), nil
+	}
+	return img, nil
+}
+
+// iftMask is the tunnel background flag of legacy single-layer bitmaps.
+const iftMask = 0x80
+
+// tunnelColor returns the color of the Tunnel material used as background of
+// IFT pixels.
+func tunnelColor(texMap TexMap, palette [256]color.RGBA) color.RGBA {
+	best := -1
+	for i, e := range texMap {
+		if i != 0 && strings.EqualFold(e.Material, "Tunnel") && (best < 0 || i < best) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nameColor("Tunnel")
+	}
+	return palette[best]
+}
+
+// readBMP decodes the named bitmap, returning nil if it doesn't exist.
+func readBMP(fsys fs.FS, name string) (*image.Paletted, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	img, err := DecodeBMP(bytes.NewReader(data))
+	if err != nil {
+		return nil, &fs.PathError{Op: "decode", Path: name, Err: err}
+	}
+	return img, nil
+}
+
+// loadTexMap combines the texture maps of the material groups with a
+// TexMap.txt directly in the scenario. Entries of earlier sources win.
+func loadTexMap(scenario fs.FS, sources []fs.FS) (TexMap, error) {
+	texMap := make(TexMap)
+	add := func(fsys fs.FS) error {
+		data, err := fs.ReadFile(fsys, c4group.C4CFN_TexMap)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		m, err := ParseTexMap(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		for i, e := range m {
+			if _, ok := texMap[i]; !ok {
+				texMap[i] = e
+			}
+		}
+		return nil
+	}
+	for _, fsys := range append([]fs.FS{scenario}, sources...) {
+		if err := add(fsys); err != nil {
+			return nil, err
+		}
+	}
+	return texMap, nil
+}
+
+// colors determines the color of each index from the average color of its
+// texture. Missing textures get a color derived from the material name.
+func colors(texMap TexMap, sources []fs.FS) ([256]color.RGBA, error) {
+	var palette [256]color.RGBA
+	textures := make(map[string]color.RGBA)
+	for i, e := range texMap {
+		if i == 0 {
+			continue
+		}
+		name := strings.ToLower(e.Texture)
+		c, ok := textures[name]
+		if !ok {
+			var err error
+			if c, ok, err = textureColor(sources, name); err != nil {
+				return palette, err
+			}
+			if !ok {
+				c = nameColor(e.Material)
+			}
+			textures[name] = c
+		}
+		palette[i] = c
+	}
+	return palette, nil
+}
+
+// textureColor finds a texture image like earth.png and averages it.
+func textureColor(sources []fs.FS, texture string) (color.RGBA, bool, error) {
+	for _, fsys := range sources {
+		entries, err := fs.ReadDir(fsys, ".")
+		if err != nil {
+			return color.RGBA{}, false, err
+		}
+		for _, e := range entries {
+			name := e.Name()
+			ext := path.Ext(name)
+			if e.IsDir() || !strings.EqualFold(strings.TrimSuffix(name, ext), texture) {
+				continue
+			}
+			switch strings.ToLower(ext) {
+			case ".png", ".jpg", ".jpeg":
+			default:
+				continue
+			}
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return color.RGBA{}, false, err
+			}
+			img, _, err := image.Decode(bytes.NewReader(data))
+			if err != nil {
+				// Broken textures shouldn't prevent a preview.
+				continue
+			}
+			return average(img), true, nil
+		}
+	}
+	return color.RGBA{}, false, nil
+}
+
+func average(img image.Image) color.RGBA {
+	var r, g, b, n uint64
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			cr, cg, cb, _ := img.At(x, y).RGBA()
+			r += uint64(cr >> 8)
+			g += uint64(cg >> 8)
+			b += uint64(cb >> 8)
+			n++
+		}
+	}
+	if n == 0 {
+		return color.RGBA{A: 0xff}
+	}
+	return color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), 0xff}
+}
+
+// nameColor returns a stable color for a material without texture.
+func nameColor(name string) color.RGBA {
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(name)))
+	sum := h.Sum32()
+	return color.RGBA{uint8(sum), uint8(sum >> 8), uint8(sum >> 16), 0xff}
+}
+
+func darken(c color.RGBA) color.RGBA {
+	return color.RGBA{c.R / 2, c.G / 2, c.B / 2, c.A}
+}
+
+// scale resizes img to the given width with nearest-neighbor sampling.
+func scale(img *image.RGBA, width int) *image.RGBA {
+	bounds := img.Bounds()
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/width
+			out.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+	return out
+}
@@ -0,0 +1,147 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+package preview
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"testing"
+	"testing/fstest"
+)
+
+// encodeBMP writes a bottom-up 8-bit bitmap with a grayscale palette.
+func encodeBMP(t *testing.T, width int, rows ...[]byte) []byte {
+	t.Helper()
+	stride := (width + 3) &^ 3
+	offset := 14 + 40 + 4*256
+	var buf bytes.Buffer
+	buf.WriteString("BM")
+	binary.Write(&buf, binary.LittleEndian, []uint32{uint32(offset + stride*len(rows)), 0, uint32(offset)})
+	binary.Write(&buf, binary.LittleEndian, []int32{40, int32(width), int32(len(rows))})
+	binary.Write(&buf, binary.LittleEndian, []uint16{1, 8})
+	binary.Write(&buf, binary.LittleEndian, make([]uint32, 6))
+	for i := 0; i < 256; i++ {
+		buf.Write([]byte{byte(i), byte(i), byte(i), 0})
+	}
+	for i := len(rows) - 1; i >= 0; i-- {
+		row := make([]byte, stride)
+		copy(row, rows[i])
+		buf.Write(row)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	for i := 0; i < 4; i++ {
+		img.Set(i%2, i/2, c)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBMP(t *testing.T) {
+	img, err := DecodeBMP(bytes.NewReader(encodeBMP(t, 3, []byte{1, 2, 3}, []byte{4, 5, 6})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Fatalf("unexpected bounds %v", img.Bounds())
+	}
+	if img.ColorIndexAt(0, 0) != 1 || img.ColorIndexAt(2, 1) != 6 {
+		t.Errorf("unexpected pixels %v", img.Pix)
+	}
+	if _, err := DecodeBMP(bytes.NewReader([]byte("BM not a bitmap"))); err == nil {
+		t.Error("expected error for invalid bitmap")
+	}
+	// Huge dimensions are rejected from the header alone.
+	for _, size := range [][2]int32{{1 << 30, 1 << 30}, {1 << 15, 1 << 15}, {1, math.MinInt32}, {1, -(1 << 30)}} {
+		data := encodeBMP(t, 1, []byte{0})
+		binary.LittleEndian.PutUint32(data[18:], uint32(size[0]))
+		binary.LittleEndian.PutUint32(data[22:], uint32(size[1]))
+		if _, err := DecodeBMP(bytes.NewReader(data)); err != ErrInvalidBMP {
+			t.Errorf("%dx%d: got %v, want ErrInvalidBMP", size[0], size[1], err)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	brown := color.RGBA{100, 50, 0, 0xff}
+	blue := color.RGBA{0, 0, 200, 0xff}
+	system := fstest.MapFS{
+		"TexMap.txt": {Data: []byte("OverloadMaterials\n1=Earth-earth\n2=Water-water\n3=Gold-gold\n")},
+		"earth.png":  {Data: encodePNG(t, color.RGBA{1, 2, 3, 0xff})},
+		"water.jpg":  {Data: []byte("broken")},
+	}
+	scenario := fstest.MapFS{
+		"MapFg.bmp":               {Data: encodeBMP(t, 4, []byte{0, 1, 2, 3}, []byte{1, 1, 0, 0})},
+		"MapBg.bmp":               {Data: encodeBMP(t, 4, []byte{0, 0, 0, 0}, []byte{0, 0, 0, 1})},
+		"Material.ocg/Earth.png":  {Data: encodePNG(t, brown)},
+		"Material.ocg/TexMap.txt": {Data: []byte("2=Water-ocean\n")},
+		"Material.ocg/ocean.png":  {Data: encodePNG(t, blue)},
+	}
+	img, err := Render(scenario, &Options{Materials: system})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba := img.(*image.RGBA)
+	check := func(x, y int, want color.RGBA) {
+		t.Helper()
+		if got := rgba.RGBAAt(x, y); got != want {
+			t.Errorf("(%d, %d): got %v, want %v", x, y, got, want)
+		}
+	}
+	check(0, 0, color.RGBA{})
+	check(1, 0, brown)
+	check(2, 0, blue)
+	check(3, 0, nameColor("Gold"))
+	check(2, 1, color.RGBA{})
+	check(3, 1, darken(brown))
+
+	img, err = Render(scenario, &Options{Materials: system, Width: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 2, 1) {
+		t.Errorf("scaled: unexpected bounds %v", img.Bounds())
+	}
+
+	// A legacy single-layer map with IFT pixels.
+	legacy := fstest.MapFS{
+		"Map.bmp":    {Data: encodeBMP(t, 4, []byte{0x81, 0x80, 1, 0})},
+		"TexMap.txt": {Data: []byte("1=Earth-earth\n5=Tunnel-tunnel\n")},
+		"earth.png":  {Data: encodePNG(t, brown)},
+	}
+	img, err = Render(legacy, &Options{Materials: legacy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rgba = img.(*image.RGBA)
+	check(0, 0, brown)
+	check(1, 0, darken(nameColor("Tunnel")))
+	check(2, 0, brown)
+	check(3, 0, color.RGBA{})
+
+	if _, err := Render(fstest.MapFS{"Map.c": {}}, nil); err != ErrNoMap {
+		t.Errorf("Map.c: got %v, want ErrNoMap", err)
+	}
+}
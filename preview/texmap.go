@@ -0,0 +1,54 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+package preview
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// TexMapEntry is a material-texture pair, e.g. Earth-earth.
+type TexMapEntry struct {
+	Material, Texture string
+}
+
+// TexMap maps color indices of scenario maps to materials and textures.
+type TexMap map[int]TexMapEntry
+
+// ParseTexMap reads TexMap.txt with "<index>=<material>-<texture>" lines.
+// Other lines like OverloadMaterials are ignored.
+func ParseTexMap(r io.Reader) (TexMap, error) {
+	m := make(TexMap)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		eq := strings.IndexByte(line, '=')
+		if eq <= 0 {
+			continue
+		}
+		index, err := strconv.Atoi(line[:eq])
+		if err != nil || index < 0 || index > 255 {
+			continue
+		}
+		value := line[eq+1:]
+		dash := strings.IndexByte(value, '-')
+		if dash < 0 {
+			continue
+		}
+		m[index] = TexMapEntry{Material: value[:dash], Texture: value[dash+1:]}
+	}
+	return m, scanner.Err()
+}
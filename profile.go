@@ -0,0 +1,142 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"path"
+	"strings"
+)
+
+// Profile describes the file names used by an engine generation.
+type Profile struct {
+	Name string
+	// GroupExtensions lists the extensions of group files, e.g. ".ocd".
+	GroupExtensions []string
+
+	sortLists []groupSortList
+}
+
+var (
+	// OpenClonk is the profile of OpenClonk with .oc? extensions.
+	OpenClonk = newProfile("OpenClonk", []string{".ocd", ".ocs", ".ocf", ".ocg", ".ocp", ".oci", ".ocm", ".ocu"}, sortLists)
+	// ClonkRage is the profile of Clonk Rage and Clonk Endeavour with .c4?
+	// extensions.
+	ClonkRage = newProfile("Clonk Rage", []string{".c4d", ".c4s", ".c4f", ".c4g", ".c4p", ".c4i", ".c4m", ".c4u"}, legacySortLists)
+
+	// Profiles lists all known profiles.
+	Profiles = []*Profile{OpenClonk, ClonkRage}
+)
+
+func newProfile(name string, extensions []string, lists []string) *Profile {
+	p := &Profile{Name: name, GroupExtensions: extensions}
+	for i := 0; i < len(lists); i += 2 {
+		p.sortLists = append(p.sortLists, groupSortList{lists[i], CompileSortList(lists[i+1])})
+	}
+	return p
+}
+
+// IsGroup reports whether name has one of the profile's group extensions.
+func (p *Profile) IsGroup(name string) bool {
+	ext := path.Ext(name)
+	for _, e := range p.GroupExtensions {
+		if strings.EqualFold(ext, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// SortOrder returns the profile's sort order for a group with the given
+// name, or nil if the profile has no sort list for it.
+func (p *Profile) SortOrder(groupName string) *SortOrder {
+	for _, l := range p.sortLists {
+		if WildcardMatch(l.pattern, groupName) {
+			return l.order
+		}
+	}
+	return nil
+}
+
+// DetectProfile returns the profile whose group extensions include the
+// extension of name, or nil.
+func DetectProfile(name string) *Profile {
+	for _, p := range Profiles {
+		if p.IsGroup(name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// legacyExtensions maps Clonk Rage extensions to their OpenClonk equivalents.
+var legacyExtensions = map[string]string{
+	".c4d": ".ocd",
+	".c4s": ".ocs",
+	".c4f": ".ocf",
+	".c4g": ".ocg",
+	".c4p": ".ocp",
+	".c4i": ".oci",
+	".c4m": ".ocm",
+	".c4b": ".ocb",
+	".c4v": ".ocv",
+	".c4u": ".ocu",
+}
+
+// ConvertLegacyName replaces a Clonk Rage extension of name with its
+// OpenClonk equivalent, e.g. "Objects.c4d" becomes "Objects.ocd". ok is
+// false if name has no legacy extension.
+func ConvertLegacyName(name string) (converted string, ok bool) {
+	ext := path.Ext(name)
+	newExt, ok := legacyExtensions[strings.ToLower(ext)]
+	if !ok {
+		return name, false
+	}
+	return name[:len(name)-len(ext)] + newExt, true
+}
+
+// ConvertLegacyPath converts all components of a path separated by '/' or
+// '\' with ConvertLegacyName.
+func ConvertLegacyPath(p string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexAny(p, `/\`)
+		if i < 0 {
+			break
+		}
+		name, _ := ConvertLegacyName(p[:i])
+		b.WriteString(name)
+		b.WriteByte(p[i])
+		p = p[i+1:]
+	}
+	name, _ := ConvertLegacyName(p)
+	b.WriteString(name)
+	return b.String()
+}
+
+// Sort lists from Clonk Rage's C4Components.h
+var legacySortLists = []string{
+	"System.c4g", "*.hlp|*.cnt|Language*.txt|*.fon|*.fnt|*.ttf|*.ttc|*.fot|*.otf|Fonts.txt|StringTbl*.txt|*.c|Names.txt",
+	"Material.c4g", "TexMap.txt|*.bmp|*.png|*.c4m",
+	"Graphics.c4g", "Loader*.bmp|Loader*.png|Loader*.jpeg|Loader*.jpg|Font*.png|*.pal|Control.png|Fire.png|Background.png|Flag.png|Crew.png|Wealth.png|Player.png|Rank.png|Entry.png|Captain.png|Cursor.png|SelectMark.png|MenuSymbol.png|Menu.png|Logo.png|Construction.png|Energy.png|Magic.png|Options.png|UpperBoard.png|Arrow.png|Exit.png|Hand.png|Gamepad.png|Build.png" +
+		"|StartupMainMenuBG.*|StartupScenSelBG.*|StartupPlrSelBG.*|StartupPlrPropBG.*|StartupNetworkBG.*|StartupAboutBG.*|StartupBigButton.png|StartupBigButtonDown.png|StartupBookScroll.png|StartupContext.png|StartupScenSelIcons.png|StartupScenSelTitleOv.png|StartupDlgPaper.png|StartupOptionIcons.png|StartupTabClip.png|StartupNetGetRef.png|StartupLogo.png",
+	"*.c4d", "Particle.txt|DefCore.txt|Graphics.png|Graphics.bmp|Overlay.png|Portrait*.png|Portrait*.bmp|Graphics*.png|Graphics*.bmp|Overlay*.png|Portraits.c4g|ActMap.txt|Script.c|Script*.c|C4Script.c|Names*.txt|Title*.txt|ClonkNames.txt|Rank.txt|Rank*.txt|Desc*.rtf|Desc*.txt|Author.txt|Version.txt|*.wav|*.ogg|*.c4d",
+	"*.c4p", "Player.txt|*.c4i",
+	"*.c4i", "ObjectInfo.txt",
+	"*.c4s", "Loader*.bmp|Loader*.png|Loader*.jpeg|Loader*.jpg|Fonts.txt|Scenario.txt|Title*.txt|Info.txt|Desc*.rtf|Desc*.txt|Icon.png|Icon.bmp|Game.txt|StringTbl*.txt|Teams.txt|Parameters.txt|Info.txt|Sect*.c4g|Music.c4g|*.mid|*.wav|Desc*.rtf|Desc*.txt|Title.png|Title.jpg|*.c4d|Script.c|Script*.c|System.c4g|Material.c4g|MatMap.txt|Map.bmp|Landscape.bmp|Sky.bmp|PXS.c4b|MassMover.c4b|CtrlRec.c4b|Strings.txt|Objects.txt|RoundResults.txt|Author.txt|Version.txt|Names.txt",
+	"*.c4f", "Folder.txt|Title*.txt|Info.txt|Desc*.rtf|Desc*.txt|Title.png|Title.jpg|Icon.png|Icon.bmp|Author.txt|Version.txt|*.c4s|Loader*.bmp|Loader*.png|Loader*.jpeg|Loader*.jpg|FolderMap.txt|FolderMap.png",
+	"Sect*.c4g", "Scenario.txt|Game.txt|Map.bmp|Landscape.bmp|Sky.bmp|PXS.c4b|MassMover.c4b|CtrlRec.c4b|Strings.txt|Objects.txt",
+	"Sound.c4g", "*.wav|*.ogg|*.c4g",
+	"Music.c4g", "Frontend.*|Credits.*",
+}
@@ -19,8 +19,11 @@ import (
 	"compress/gzip"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
+	"math"
 )
 
 var (
@@ -35,11 +38,17 @@ type Reader struct {
 	Header  Header  // valid after NewReader
 	Entries []Entry // same
 
+	// VerifyCRC enables checksum verification of file entries. If an entry
+	// has a checksum, Read returns ErrCRCMismatch at the end of the entry
+	// if the data doesn't match. Child groups are not verified.
+	VerifyCRC bool
+
 	r       io.Reader
 	gz      *gzip.Reader
 	offset  int // offset after all headers
 	curFile int // index of current file
 	entries []entry
+	crc     uint32 // CRC32 of the current file's data read so far
 }
 
 // magicBytesReader is an adapter for the c4group magic bytes to gzip magic bytes.
@@ -93,53 +102,115 @@ func NewReader(r io.Reader) (*Reader, error) {
 	return cr, nil
 }
 
+// NewRawReader creates a reader for a raw group stream without magic bytes
+// and compression, like the data of a child group.
+func NewRawReader(r io.Reader) (*Reader, error) {
+	cr := &Reader{r: r}
+	if err := cr.init(); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
 // init initialized the reader by reading the header structures.
 func (cr *Reader) init() error {
 	cr.curFile = -1
+	var err error
+	cr.entries, cr.Entries, err = readHeaders(cr.r, &cr.Header, math.MaxInt64)
+	return err
+}
+
+// readHeaders reads the initial group header and all entry headers of a group
+// with at most size bytes of uncompressed data.
+func readHeaders(r io.Reader, hdr *Header, size int64) ([]entry, []Entry, error) {
 	// Read main header.
-	if err := cr.readHeader(); err != nil {
-		return err
+	if err := readHeader(r, hdr); err != nil {
+		return nil, nil, err
+	}
+	if int64(hdr.Entries) > (size-HeaderSize)/EntrySize {
+		return nil, nil, &HeaderError{Field: "Entries", Value: fmt.Sprint(hdr.Entries)}
 	}
-	// Read all entry headers.
-	cr.Entries = make([]Entry, cr.Header.Entries)
-	cr.entries = make([]entry, cr.Header.Entries)
-	for i := int32(0); i < cr.Header.Entries; i++ {
-		err := cr.readEntry(&cr.entries[i])
-		publicEntry(&cr.Entries[i], &cr.entries[i])
+	// Read all entry headers. The count isn't trusted for allocation as the
+	// size of packed groups is unknown.
+	n := int(hdr.Entries)
+	if n > maxPreallocEntries {
+		n = maxPreallocEntries
+	}
+	public := make([]Entry, 0, n)
+	private := make([]entry, 0, n)
+	for i := int32(0); i < hdr.Entries; i++ {
+		var e entry
+		var pe Entry
+		err := readEntry(r, &e)
+		legacyEntry(&e, hdr.Ver2)
+		publicEntry(&pe, &e)
+		private = append(private, e)
+		public = append(public, pe)
 		if err != nil {
-			return err
+			return private, public, err
 		}
 	}
-	return nil
+	return private, public, nil
 }
 
+// maxPreallocEntries limits the entry headers allocated before reading them.
+const maxPreallocEntries = 1024
+
 // readHeader reads the initial group header.
-func (cr *Reader) readHeader() error {
+func readHeader(r io.Reader, hdr *Header) error {
 	header := header{}
 	headerSize := binary.Size(&header)
 	var buf bytes.Buffer
-	_, err := io.CopyN(&buf, cr.r, int64(headerSize))
+	_, err := io.CopyN(&buf, r, int64(headerSize))
 	if err != nil {
 		return err
 	}
-	memScramble(buf.Bytes())
+	// Some tools don't scramble the header.
+	unscrambled := bytes.HasPrefix(buf.Bytes(), []byte(C4GroupFileID))
+	if !unscrambled {
+		memScramble(buf.Bytes())
+	}
 	err = binary.Read(&buf, binary.LittleEndian, &header)
 	if err != nil {
 		return err
 	}
 
-	if string(header.ID[:len(C4GroupFileID)]) != C4GroupFileID || header.Ver1 != C4GroupFileVer1 || header.Ver2 != C4GroupFileVer2 {
-		return ErrInvalidHeader
+	// Like the engine, accept all versions up to the current one.
+	switch {
+	case string(header.ID[:len(C4GroupFileID)]) != C4GroupFileID:
+		return &HeaderError{Field: "ID", Value: fmt.Sprintf("%q", header.ID[:clen(header.ID[:])])}
+	case header.Ver1 != C4GroupFileVer1:
+		return &HeaderError{Field: "Ver1", Value: fmt.Sprint(header.Ver1)}
+	case header.Ver2 < 0 || header.Ver2 > C4GroupFileVer2:
+		return &HeaderError{Field: "Ver2", Value: fmt.Sprint(header.Ver2)}
+	case header.Entries < 0:
+		return &HeaderError{Field: "Entries", Value: fmt.Sprint(header.Entries)}
 	}
 
-	publicHeader(&cr.Header, &header)
+	publicHeader(hdr, &header)
+	hdr.Unscrambled = unscrambled
 
 	return nil
 }
 
+// HeaderError reports an invalid group header field. It wraps
+// ErrInvalidHeader.
+type HeaderError struct {
+	Field string // ID, Ver1, Ver2 or Entries
+	Value string // value found in the header
+}
+
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("c4group: invalid header field %s: %s", e.Field, e.Value)
+}
+
+func (e *HeaderError) Unwrap() error {
+	return ErrInvalidHeader
+}
+
 // readEntry reads a single entry header.
-func (cr *Reader) readEntry(e *entry) error {
-	err := binary.Read(cr.r, binary.LittleEndian, e)
+func readEntry(r io.Reader, e *entry) error {
+	err := binary.Read(r, binary.LittleEndian, e)
 	if err != nil {
 		return err
 	}
@@ -162,25 +233,50 @@ func (cr *Reader) Next() (*Entry, error) {
 		return nil, err
 	}
 	cr.offset += int(n)
+	cr.crc = 0
 	return &cr.Entries[cr.curFile], nil
 }
 
 // Read from the current file. Returns io.EOF after finishing.
 func (cr *Reader) Read(b []byte) (int, error) {
 	entry := &cr.entries[cr.curFile]
-	if cr.offset >= int(entry.Offset+entry.Size) {
+	end := int(entry.Offset + entry.Size)
+	if cr.offset >= end {
+		if err := cr.verifyCRC(); err != nil {
+			return 0, err
+		}
 		return 0, io.EOF
 	}
 	// Ensure that we don't read into the next file.
-	max := int(entry.Size) - (cr.offset - int(entry.Offset))
+	max := end - cr.offset
 	if len(b) > max {
 		b = b[:max]
 	}
 	n, err := cr.r.Read(b)
 	cr.offset += n
+	if cr.VerifyCRC {
+		cr.crc = crc32.Update(cr.crc, crc32.IEEETable, b[:n])
+		if cr.offset >= end && (err == nil || err == io.EOF) {
+			if verr := cr.verifyCRC(); verr != nil {
+				err = verr
+			}
+		}
+	}
 	return n, err
 }
 
+// verifyCRC checks the current file's checksum after reading all data.
+func (cr *Reader) verifyCRC() error {
+	entry := &cr.entries[cr.curFile]
+	if !cr.VerifyCRC || entry.HasCRC == crcNone || entry.ChildGroup != 0 {
+		return nil
+	}
+	if fileCRC(cr.crc, int64(entry.Size), cr.Entries[cr.curFile].Filename, entry.HasCRC) != entry.CRC {
+		return ErrCRCMismatch
+	}
+	return nil
+}
+
 // ReadGroup reads a sub group from the archive.
 func (cr *Reader) ReadGroup() (*Reader, error) {
 	entry := &cr.entries[cr.curFile]
@@ -190,7 +286,7 @@ func (cr *Reader) ReadGroup() (*Reader, error) {
 	if int(entry.Offset) != cr.offset {
 		return nil, ErrAlreadyRead
 	}
-	sub := &Reader{r: cr}
+	sub := &Reader{r: cr, VerifyCRC: cr.VerifyCRC}
 	if err := sub.init(); err != nil {
 		return nil, err
 	}
@@ -18,8 +18,9 @@
 package c4group
 
 import (
-	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // Groups are sorted to speed up loading. Sort data is from c4group/C4Components.h
@@ -230,38 +231,133 @@ var sortLists = []string{
 	C4CFN_Music, C4FLS_Music,
 }
 
+var (
+	sortListsMu sync.RWMutex
+	// custom sort lists registered with RegisterSortList, latest first
+	customSortLists []groupSortList
+)
+
+type groupSortList struct {
+	pattern string
+	order   *SortOrder
+}
+
+// RegisterSortList sets the sort list for groups whose name matches the
+// wildcard pattern, e.g. for custom group extensions. Registered lists take
+// precedence over the lists of all Profiles and earlier registrations.
+func RegisterSortList(pattern, list string) {
+	sortListsMu.Lock()
+	defer sortListsMu.Unlock()
+	customSortLists = append([]groupSortList{{pattern, CompileSortList(list)}}, customSortLists...)
+}
+
+// GroupSortOrder returns the sort order for a group with the given name.
+// Both OpenClonk and Clonk Rage groups are recognized, see Profiles.
+func GroupSortOrder(groupName string) *SortOrder {
+	sortListsMu.RLock()
+	defer sortListsMu.RUnlock()
+	for _, l := range customSortLists {
+		if WildcardMatch(l.pattern, groupName) {
+			return l.order
+		}
+	}
+	for _, p := range Profiles {
+		if o := p.SortOrder(groupName); o != nil {
+			return o
+		}
+	}
+	return &SortOrder{}
+}
+
 // NameLess returns a name sorting function (Less) for a group with the given
 // name.
 func NameLess(groupName string) func(child1, child2 string) bool {
-	var sortList []string
-	for i := 0; i < len(sortLists); i += 2 {
-		if m, _ := filepath.Match(sortLists[i], groupName); m {
-			sortList = strings.Split(sortLists[i+1], "|")
-			for j := range sortList {
-				sortList[j] = strings.ToLower(sortList[j])
-			}
-			break
-		}
+	return GroupSortOrder(groupName).Less
+}
+
+// SortListLess returns a name sorting function (Less) for the given
+// '|'-separated sort list, see SortOrder.
+func SortListLess(list string) func(child1, child2 string) bool {
+	return CompileSortList(list).Less
+}
+
+// SortOrder is a compiled '|'-separated sort list. Names matching earlier
+// patterns sort first, names not matching any pattern sort last. Otherwise,
+// names are sorted alphabetically. Patterns are matched like with
+// WildcardMatch.
+type SortOrder struct {
+	patterns []wildcard
+}
+
+// CompileSortList parses a sort list. Each pattern is split into its literal
+// parts once, so that matching doesn't have to interpret it again.
+func CompileSortList(list string) *SortOrder {
+	if list == "" {
+		return &SortOrder{}
 	}
-	return func(child1, child2 string) bool {
-		child1 = strings.ToLower(child1)
-		child2 = strings.ToLower(child2)
-		if sortList != nil {
-			for _, pattern := range sortList {
-				m1, _ := filepath.Match(pattern, child1)
-				m2, _ := filepath.Match(pattern, child2)
-				if m1 || m2 {
-					if m1 && !m2 {
-						return true
-					}
-					if !m1 && m2 {
-						return false
-					}
-					// both in the same group
-					break
-				}
-			}
+	o := &SortOrder{}
+	for _, pattern := range strings.Split(list, "|") {
+		o.patterns = append(o.patterns, compileWildcard(pattern))
+	}
+	return o
+}
+
+// Rank returns the index of the first pattern matching name, or the number
+// of patterns if none matches.
+func (o *SortOrder) Rank(name string) int {
+	name = toLower(name)
+	for i, pattern := range o.patterns {
+		if pattern.match(name) {
+			return i
 		}
-		return child1 < child2
 	}
+	return len(o.patterns)
+}
+
+// Less reports whether name1 sorts before name2. It ranks both names on
+// every call; use Sorter to sort many names.
+func (o *SortOrder) Less(name1, name2 string) bool {
+	return sortKey{o.Rank(name1), toLower(name1)}.less(sortKey{o.Rank(name2), toLower(name2)})
+}
+
+// Sorter returns a sort.Interface which sorts names in place. The rank of
+// each name is computed only once.
+func (o *SortOrder) Sorter(names []string) sort.Interface {
+	return o.sorter(len(names), func(i int) string { return names[i] }, func(i, j int) {
+		names[i], names[j] = names[j], names[i]
+	})
+}
+
+// sorter returns a sort.Interface for n items with the given names. swap is
+// called to swap the items.
+func (o *SortOrder) sorter(n int, name func(i int) string, swap func(i, j int)) sort.Interface {
+	s := &keySorter{keys: make([]sortKey, n), swap: swap}
+	for i := range s.keys {
+		s.keys[i] = sortKey{o.Rank(name(i)), toLower(name(i))}
+	}
+	return s
+}
+
+type sortKey struct {
+	rank int
+	name string // ASCII lower case, like the names matched by Rank
+}
+
+func (k sortKey) less(other sortKey) bool {
+	if k.rank != other.rank {
+		return k.rank < other.rank
+	}
+	return k.name < other.name
+}
+
+type keySorter struct {
+	keys []sortKey
+	swap func(i, j int)
+}
+
+func (s *keySorter) Len() int           { return len(s.keys) }
+func (s *keySorter) Less(i, j int) bool { return s.keys[i].less(s.keys[j]) }
+func (s *keySorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.swap(i, j)
 }
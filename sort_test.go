@@ -15,6 +15,9 @@
 package c4group
 
 import (
+	"bytes"
+	"io/ioutil"
+	"sort"
 	"testing"
 )
 
@@ -35,4 +38,121 @@ func TestNameLess(t *testing.T) {
 	less = NameLess("xyz")
 	check("abc", "def", true, "without groups sorts alphabetically")
 	check("xyz", "def", false, "without groups sorts alphabetically")
+
+	// Path separators aren't special, like in the engine.
+	less = NameLess("Packs/Objects.ocd")
+	check("DefCore.txt", "Script.c", true, "group name with path separator")
+	less = NameLess(`Packs\Objects.ocd`)
+	check("DefCore.txt", "Script.c", true, "group name with backslash")
+	less = SortListLess(`a\*|[b]`)
+	check(`a\b`, "x", true, "backslash in pattern")
+	check("[b]", "x", true, "brackets in pattern")
+}
+
+func TestSortOrder(t *testing.T) {
+	names := []string{"Script.c", "zzz.txt", "DefCore.txt", "Graphics.png", "aaa.txt"}
+	sort.Stable(GroupSortOrder("Clonk.ocd").Sorter(names))
+	want := []string{"Graphics.png", "DefCore.txt", "Script.c", "aaa.txt", "zzz.txt"}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got %v, want %v", names, want)
+		}
+	}
+
+	order := CompileSortList("*.txt|*.c")
+	if r := order.Rank("Foo.TXT"); r != 0 {
+		t.Errorf("Foo.TXT: got rank %d, want 0", r)
+	}
+	if r := order.Rank("Foo.png"); r != 2 {
+		t.Errorf("Foo.png: got rank %d, want 2", r)
+	}
+
+	// Names are compared with the same ASCII case folding as patterns.
+	if !order.Less("Äb", "äa") || order.Less("äa", "Äb") {
+		t.Error("non-ASCII names aren't ordered by their bytes")
+	}
+
+	saved := customSortLists
+	t.Cleanup(func() { customSortLists = saved })
+	RegisterSortList("*.octest", "b*|a*")
+	less := NameLess("Foo.OCTEST")
+	if !less("bar", "abc") {
+		t.Error("registered sort list isn't used")
+	}
+}
+
+func TestSort(t *testing.T) {
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := NewBuilder("Test.ocs")
+	b.AddFile(Entry{Filename: "Script.c", Size: 5}, stringFile("Hello"))
+	objects := b.AddGroup(Entry{Filename: "Objects.ocd"})
+	clonk := objects.AddGroup(Entry{Filename: "Clonk.ocd"})
+	clonk.AddFile(Entry{Filename: "Script.c", Size: 6}, stringFile("func()"))
+	clonk.AddFile(Entry{Filename: "DefCore.txt", Size: 9}, stringFile("[DefCore]"))
+	b.AddFile(Entry{Filename: "Scenario.txt", Size: 6}, stringFile("[Head]"))
+	var unsorted bytes.Buffer
+	check(b.Pack(&unsorted))
+
+	r, err := NewReader(bytes.NewReader(unsorted.Bytes()))
+	check(err)
+	misplaced, err := CheckOrder("Test.ocs", r)
+	check(err)
+	checkNames(t, "misplaced", misplaced, "Script.c", "Objects.ocd", "Objects.ocd/Clonk.ocd/Script.c")
+
+	r, err = NewReader(bytes.NewReader(unsorted.Bytes()))
+	check(err)
+	var sorted bytes.Buffer
+	check(Sort(&sorted, "Test.ocs", r))
+	r, err = NewReader(bytes.NewReader(sorted.Bytes()))
+	check(err)
+	misplaced, err = CheckOrder("Test.ocs", r)
+	check(err)
+	checkNames(t, "misplaced after sorting", misplaced)
+
+	g, err := OpenReaderAt(bytes.NewReader(sorted.Bytes()), int64(sorted.Len()))
+	check(err)
+	checkNames(t, "root", entryNames(g), "Scenario.txt", "Objects.ocd", "Script.c")
+	f, err := g.Open("Objects.ocd/Clonk.ocd/DefCore.txt")
+	check(err)
+	data, err := ioutil.ReadAll(f)
+	check(err)
+	if string(data) != "[DefCore]" {
+		t.Errorf("DefCore.txt: got %q", data)
+	}
+
+	// Sorting a group file with the Editor gives the same result.
+	g, err = OpenReaderAt(bytes.NewReader(unsorted.Bytes()), int64(unsorted.Len()))
+	check(err)
+	ed := NewEditor("Test.ocs", g)
+	check(ed.SortAll())
+	var edited bytes.Buffer
+	check(ed.Pack(&edited))
+	if !bytes.Equal(edited.Bytes(), sorted.Bytes()) {
+		t.Error("Editor.SortAll and Sort differ")
+	}
+}
+
+func TestCheckOrder(t *testing.T) {
+	b := NewBuilder("Test.ocg")
+	for _, name := range []string{"Z.txt", "A.txt", "B.txt", "C.txt"} {
+		b.AddFile(Entry{Filename: name, Size: 1}, stringFile("x"))
+	}
+	var buf bytes.Buffer
+	if err := b.Pack(&buf); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	misplaced, err := CheckOrder("Test.ocg", r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkNames(t, "misplaced", misplaced, "Z.txt")
 }
@@ -0,0 +1,332 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/lluchs/c4group-go/core"
+)
+
+var (
+	ErrUpdateSource      error = errors.New("c4group: group doesn't match the update's source checksums")
+	ErrAlreadyUpdated    error = errors.New("c4group: update has already been applied")
+	ErrUpdateResult      error = errors.New("c4group: updated group doesn't match the update's checksum")
+	ErrUnsupportedUpdate error = errors.New("c4group: only group updates are supported")
+)
+
+// UpdateOptions configures MakeUpdate.
+type UpdateOptions struct {
+	// Name of the updated group, e.g. "Objects.ocd". It is also used as
+	// destination path.
+	Name string
+	// RequireVersion restricts the update to an engine version, e.g.
+	// [8, 0, 0, 0]. Empty for any version.
+	RequireVersion []int
+}
+
+// MakeUpdate writes an update group (C4CFN_UpdateGroupExtension) which
+// turns old into new like the engine's update packages. It contains
+// C4CFN_UpdateCore with the checksums of both groups and, for each changed
+// group, the changed files and a C4CFN_UpdateEntries list of all entries.
+// Unchanged child groups are left out. opts may be nil.
+func MakeUpdate(w io.Writer, old, new *Group, opts *UpdateOptions) error {
+	if opts == nil {
+		opts = &UpdateOptions{}
+	}
+	oldCRCs, err := groupCRCs(old)
+	if err != nil {
+		return err
+	}
+	newCRCs, err := groupCRCs(new)
+	if err != nil {
+		return err
+	}
+
+	version := opts.RequireVersion
+	if len(version) == 0 {
+		version = []int{0, 0, 0, 0}
+	}
+	upd := core.Update{Update: core.UpdateSection{
+		RequireVersion: version,
+		Name:           opts.Name,
+		DestPath:       opts.Name,
+		GrpUpdate:      1,
+		TargetCount:    1,
+		GrpChks1:       []uint32{oldCRCs.sum()},
+		GrpChks2:       newCRCs.sum(),
+	}}
+	f := core.NewFile()
+	if err := core.Marshal(f, &upd); err != nil {
+		return err
+	}
+	coreData := f.Bytes()
+
+	b := NewBuilder(opts.Name)
+	b.AddFile(Entry{Filename: C4CFN_UpdateCore, Size: len(coreData)}, bytesFile(coreData))
+	if _, err := makeUpdate(b, old, new, oldCRCs, newCRCs); err != nil {
+		return err
+	}
+	return b.Pack(w)
+}
+
+// makeUpdate adds the changes from old to new to b. old may be nil for new
+// child groups. It returns whether anything changed.
+func makeUpdate(b *Builder, old, new *Group, oldCRCs, newCRCs *crcTree) (bool, error) {
+	modified := old == nil || old.Header.Author != new.Header.Author ||
+		!old.Header.Ctime.Equal(new.Header.Ctime) || old.Header.IsOriginal != new.Header.IsOriginal ||
+		len(old.Entries) != len(new.Entries)
+	b.Header = new.Header
+
+	var list []string
+	for i := range new.Entries {
+		e := &new.Entries[i]
+		// The engine writes the checksums as signed integers.
+		list = append(list, fmt.Sprintf("%s=%d", e.Filename, int32(newCRCs.crcs[i])))
+		oldIndex := -1
+		if old != nil {
+			oldIndex, _ = old.index(e.Filename)
+			if !modified && old.Entries[i].Filename != e.Filename {
+				modified = true
+			}
+		}
+
+		if e.IsGroup {
+			child, err := new.child(i)
+			if err != nil {
+				return false, err
+			}
+			var oldChild *Group
+			var oldChildCRCs *crcTree
+			if oldIndex >= 0 && old.Entries[oldIndex].IsGroup {
+				if oldChild, err = old.child(oldIndex); err != nil {
+					return false, err
+				}
+				oldChildCRCs = oldCRCs.children[oldIndex]
+			}
+			sub := NewBuilder(e.Filename)
+			changed, err := makeUpdate(sub, oldChild, child, oldChildCRCs, newCRCs.children[i])
+			if err != nil {
+				return false, err
+			}
+			if changed {
+				b.entries = append(b.entries, &builderEntry{Entry: Entry{Filename: e.Filename, IsGroup: true, Mtime: e.Mtime}, group: sub})
+				modified = true
+			}
+			continue
+		}
+		if oldIndex < 0 || old.Entries[oldIndex].IsGroup || old.Entries[oldIndex].Size != e.Size || oldCRCs.crcs[oldIndex] != newCRCs.crcs[i] {
+			entry := *e
+			entry.HasCRC, entry.CRC = true, newCRCs.crcs[i]
+			b.AddFile(entry, sectionFile(new, i))
+			modified = true
+		}
+	}
+	entries := []byte(strings.Join(list, "|"))
+	b.AddFile(Entry{Filename: C4CFN_UpdateEntries, Size: len(entries)}, bytesFile(entries))
+	return modified, nil
+}
+
+// ApplyUpdate applies the update group upd to base and writes the result to
+// w. name is the filename of base, which determines the sort order of new
+// entries. The checksum of base must match one of the update's source
+// checksums; the result is verified before writing.
+func ApplyUpdate(w io.Writer, name string, base, upd *Group) error {
+	r, err := upd.Open(C4CFN_UpdateCore)
+	if err != nil {
+		return fmt.Errorf("%s: %w", C4CFN_UpdateCore, err)
+	}
+	var uc core.Update
+	if _, err := core.Decode(r, &uc); err != nil {
+		return fmt.Errorf("%s: %w", C4CFN_UpdateCore, err)
+	}
+	if uc.Update.GrpUpdate == 0 {
+		return ErrUnsupportedUpdate
+	}
+	baseCRCs, err := groupCRCs(base)
+	if err != nil {
+		return err
+	}
+	crc := baseCRCs.sum()
+	if crc == uc.Update.GrpChks2 {
+		return ErrAlreadyUpdated
+	}
+	found := false
+	for _, c := range uc.Update.GrpChks1 {
+		found = found || c == crc
+	}
+	if !found {
+		return ErrUpdateSource
+	}
+
+	b := builderFromGroup(name, base)
+	if err := applyUpdate(b, upd, true); err != nil {
+		return err
+	}
+	if err := b.ComputeCRC(); err != nil {
+		return err
+	}
+	var result uint32
+	for _, e := range b.entries {
+		result ^= e.CRC
+	}
+	if result != uc.Update.GrpChks2 {
+		return ErrUpdateResult
+	}
+	return b.Pack(w)
+}
+
+// applyUpdate applies the update data in upd to b like the engine's
+// C4UpdatePackage::DoGrpUpdate.
+func applyUpdate(b *Builder, upd *Group, root bool) error {
+	var list []string
+	if i, err := upd.index(C4CFN_UpdateEntries); err == nil {
+		data, err := ioutil.ReadAll(upd.section(i))
+		if err != nil {
+			return err
+		}
+		for _, item := range strings.Split(string(data), "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, strings.SplitN(item, "=", 2)[0])
+			}
+		}
+		// Delete all entries which aren't in the list.
+		keep := make(map[string]bool)
+		for _, name := range list {
+			keep[name] = true
+		}
+		entries := b.entries[:0]
+		for _, e := range b.entries {
+			if keep[e.Filename] {
+				entries = append(entries, e)
+			}
+		}
+		b.entries = entries
+	}
+	b.Header = upd.Header
+
+	for i := range upd.Entries {
+		e := upd.Entries[i]
+		if e.Filename == C4CFN_UpdateEntries || root && e.Filename == C4CFN_UpdateCore {
+			continue
+		}
+		j := b.find(e.Filename)
+		if e.IsGroup {
+			child, err := upd.child(i)
+			if err != nil {
+				return err
+			}
+			if j < 0 || !b.entries[j].IsGroup {
+				target := &builderEntry{Entry: Entry{Filename: e.Filename, IsGroup: true, Mtime: e.Mtime}, group: NewBuilder(e.Filename)}
+				if j < 0 {
+					b.entries = append(b.entries, target)
+				} else {
+					b.entries[j] = target
+				}
+				j = b.find(e.Filename)
+			}
+			target := b.entries[j]
+			if err := target.expand(); err != nil {
+				return err
+			}
+			if err := applyUpdate(target.group, child, false); err != nil {
+				return err
+			}
+			continue
+		}
+		entry := &builderEntry{Entry: e, open: sectionFile(upd, i)}
+		if j < 0 {
+			b.entries = append(b.entries, entry)
+		} else {
+			b.entries[j] = entry
+		}
+	}
+
+	// Sort by the entry list.
+	if list != nil {
+		pos := make(map[string]int)
+		for i, name := range list {
+			pos[name] = i
+		}
+		sorted := make([]*builderEntry, 0, len(b.entries))
+		for _, name := range list {
+			if j := b.find(name); j >= 0 {
+				sorted = append(sorted, b.entries[j])
+			}
+		}
+		for _, e := range b.entries {
+			if _, ok := pos[e.Filename]; !ok {
+				sorted = append(sorted, e)
+			}
+		}
+		b.entries = sorted
+	}
+	return nil
+}
+
+// crcTree contains the checksums of a group's entries, see ContentsCRC.
+type crcTree struct {
+	crcs     []uint32
+	children []*crcTree // nil for files
+}
+
+func groupCRCs(g *Group) (*crcTree, error) {
+	t := &crcTree{crcs: make([]uint32, len(g.Entries)), children: make([]*crcTree, len(g.Entries))}
+	for i := range g.Entries {
+		if g.Entries[i].IsGroup {
+			child, err := g.child(i)
+			if err != nil {
+				return nil, err
+			}
+			if t.children[i], err = groupCRCs(child); err != nil {
+				return nil, err
+			}
+			t.crcs[i] = t.children[i].sum()
+			continue
+		}
+		crc, err := FileCRC(g.Entries[i].Filename, g.section(i))
+		if err != nil {
+			return nil, err
+		}
+		t.crcs[i] = crc
+	}
+	return t, nil
+}
+
+// sum returns the checksum of the whole group.
+func (t *crcTree) sum() uint32 {
+	var crc uint32
+	for _, c := range t.crcs {
+		crc ^= c
+	}
+	return crc
+}
+
+func bytesFile(data []byte) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+func sectionFile(g *Group, i int) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(g.section(i)), nil
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	check := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	open := func(data []byte) *Group {
+		t.Helper()
+		g, err := OpenReaderAt(bytes.NewReader(data), int64(len(data)))
+		check(err)
+		return g
+	}
+	groupCRC := func(g *Group) uint32 {
+		t.Helper()
+		crcs, err := groupCRCs(g)
+		check(err)
+		return crcs.sum()
+	}
+
+	oldData := writeTestGroup(t, testData(1<<16))
+	old := open(oldData)
+	ed := NewEditor("Test.ocs", old)
+	check(ed.Add("Objects.ocd/Rock.ocd/DefCore.txt", Entry{Size: 7}, stringFile("id=Rock")))
+	check(ed.Replace("Scenario.txt", Entry{Size: 6}, stringFile("[Head]")))
+	check(ed.Delete("Objects.ocd/Clonk.ocd/Script.c"))
+	var newBuf, updBuf, buf bytes.Buffer
+	check(ed.Pack(&newBuf))
+	new := open(newBuf.Bytes())
+
+	check(MakeUpdate(&updBuf, old, new, &UpdateOptions{Name: "Test.ocs"}))
+	upd := open(updBuf.Bytes())
+	checkNames(t, "update", entryNames(upd), C4CFN_UpdateCore, "Scenario.txt", "Objects.ocd", C4CFN_UpdateEntries)
+
+	check(ApplyUpdate(&buf, "Test.ocs", old, upd))
+	result := open(buf.Bytes())
+	if got, want := groupCRC(result), groupCRC(new); got != want {
+		t.Errorf("updated group has checksum %08x, want %08x", got, want)
+	}
+	checkNames(t, "root", entryNames(result), entryNames(new)...)
+	objects, err := result.OpenGroup("Objects.ocd")
+	check(err)
+	newObjects, err := new.OpenGroup("Objects.ocd")
+	check(err)
+	checkNames(t, "Objects.ocd", entryNames(objects), entryNames(newObjects)...)
+
+	if err := ApplyUpdate(&bytes.Buffer{}, "Test.ocs", result, upd); err != ErrAlreadyUpdated {
+		t.Errorf("expected ErrAlreadyUpdated, got %v", err)
+	}
+	if err := ApplyUpdate(&bytes.Buffer{}, "Test.ocs", objects, upd); err != ErrUpdateSource {
+		t.Errorf("expected ErrUpdateSource, got %v", err)
+	}
+}
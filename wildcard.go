@@ -0,0 +1,121 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import "strings"
+
+// WildcardMatch reports whether name matches the pattern with the syntax of
+// the engine's WildcardMatch: '*' matches any sequence of characters and
+// '?' matches a single character. There are no character classes or escapes
+// and path separators are not special.
+//
+// Unlike the engine's WildcardMatch, matching is case-insensitive for ASCII
+// letters. This is deliberate: sort lists have always been matched
+// case-insensitively by NameLess, and sort lists and user-supplied wildcards
+// share this matcher.
+func WildcardMatch(pattern, name string) bool {
+	return compileWildcard(pattern).match(toLower(name))
+}
+
+// WildcardListMatch reports whether name matches any of the '|'-separated
+// patterns in list, see WildcardMatch.
+func WildcardListMatch(list, name string) bool {
+	for _, pattern := range strings.Split(list, "|") {
+		if WildcardMatch(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// lower converts an ASCII character to lower case.
+func lower(c byte) byte {
+	if 'A' <= c && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
+
+// wildcard is a pattern compiled for repeated matching, see WildcardMatch.
+type wildcard struct {
+	segments []string // lower-case literal parts between '*'
+}
+
+func compileWildcard(pattern string) wildcard {
+	return wildcard{segments: strings.Split(toLower(pattern), "*")}
+}
+
+// match is WildcardMatch for a name which is already lower case.
+func (w wildcard) match(name string) bool {
+	segs := w.segments
+	first, last := segs[0], segs[len(segs)-1]
+	if len(segs) == 1 {
+		return len(name) == len(first) && segmentMatch(first, name)
+	}
+	if len(name) < len(first)+len(last) ||
+		!segmentMatch(first, name[:len(first)]) ||
+		!segmentMatch(last, name[len(name)-len(last):]) {
+		return false
+	}
+	// Taking the leftmost match of each segment in between never prevents
+	// later segments from matching.
+	name = name[len(first) : len(name)-len(last)]
+	for _, seg := range segs[1 : len(segs)-1] {
+		i := segmentIndex(name, seg)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(seg):]
+	}
+	return true
+}
+
+// segmentMatch reports whether s matches seg, which may contain '?'. Both
+// have the same length.
+func segmentMatch(seg, s string) bool {
+	for i := 0; i < len(seg); i++ {
+		if seg[i] != '?' && seg[i] != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// segmentIndex returns the index of the first match of seg in s, or -1.
+func segmentIndex(s, seg string) int {
+	if strings.IndexByte(seg, '?') < 0 {
+		return strings.Index(s, seg)
+	}
+	for i := 0; i+len(seg) <= len(s); i++ {
+		if segmentMatch(seg, s[i:i+len(seg)]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// toLower converts the ASCII letters of s to lower case, like lower.
+func toLower(s string) string {
+	for i := 0; i < len(s); i++ {
+		if 'A' <= s[i] && s[i] <= 'Z' {
+			b := []byte(s)
+			for j := i; j < len(b); j++ {
+				b[j] = lower(b[j])
+			}
+			return string(b)
+		}
+	}
+	return s
+}
@@ -0,0 +1,59 @@
+// Copyright © 2019, Lukas Werling
+//
+// Permission to use, copy, modify, and/or distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package c4group
+
+import (
+	"testing"
+)
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{"*.ocd", "Clonk.ocd", true},
+		{"*.ocd", "Clonk.ocs", false},
+		{"*.OCD", "clonk.ocd", true},
+		{"Script*.c", "Script.c", true},
+		{"Script*.c", "ScriptDE.c", true},
+		{"Script?.c", "Script.c", false},
+		{"Graphics.*.png", "Graphics.2.png", true},
+		{"*a*b", "xaxxbxb", true},
+		{"*a*b", "xaxxbxc", false},
+		{"*/*", `a/b`, true},
+		{`*\*`, `a\b`, true},
+		{"[ab]", "a", false},
+		{"[ab]", "[ab]", true},
+		{"", "", true},
+		{"*", "", true},
+		{"?", "", false},
+		{"a*a", "a", false},
+		{"a*a", "aa", true},
+		{"*?b?*", "xBy", true},
+		{"*ab?d*", "abxabcd", true},
+		{"Sect*.OCG", "sect1.ocg", true},
+		// Only ASCII letters are folded.
+		{"ä*", "äb", true},
+		{"ä*", "Äb", false},
+	}
+	for _, test := range tests {
+		if WildcardMatch(test.pattern, test.name) != test.match {
+			t.Errorf("WildcardMatch(%q, %q) != %v", test.pattern, test.name, test.match)
+		}
+	}
+	if !WildcardListMatch("*.wav|*.ogg", "Sound.ogg") || WildcardListMatch("*.wav|*.ogg", "Sound.mp3") {
+		t.Error("WildcardListMatch failed")
+	}
+}
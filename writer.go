@@ -19,6 +19,7 @@ import (
 	"compress/gzip"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 )
 
@@ -39,6 +40,7 @@ type Writer struct {
 	haveHeader      bool  // header already written?
 	expectedEntries int32 // number of entries specified in the header
 	written         int32 // amount of file data already written
+	ver2            int32 // minor format version from the header
 }
 
 type magicBytesWriter struct {
@@ -69,6 +71,12 @@ func NewWriter(w io.Writer) *Writer {
 	return &Writer{w: gz, gz: gz}
 }
 
+// NewRawWriter creates a Writer for a raw group stream without magic bytes
+// and compression, like the data of a child group.
+func NewRawWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
 // CreateSubGroup starts a subfolder as part of the group's file data.
 func (cw *Writer) CreateSubGroup(hdr *Header) (*Writer, error) {
 	sub := &Writer{w: cw}
@@ -79,29 +87,41 @@ func (cw *Writer) CreateSubGroup(hdr *Header) (*Writer, error) {
 	return sub, nil
 }
 
-// WriteHeader writes a new group header to the group.
+// WriteHeader writes a new group header to the group. Ver1, Ver2 and
+// Unscrambled of hdr select the format variant, see Header.
 func (cw *Writer) WriteHeader(hdr *Header) error {
 	if cw.haveHeader {
 		return ErrHeaderAlreadyWritten
 	}
-	header := header{
-		Ver1: C4GroupFileVer1,
-		Ver2: C4GroupFileVer2,
-	}
+	header := header{}
 	copy(header.ID[:], C4GroupFileID)
 	privateHeader(&header, hdr)
+	if header.Ver1 != C4GroupFileVer1 {
+		return &HeaderError{Field: "Ver1", Value: fmt.Sprint(header.Ver1)}
+	}
+	if header.Ver2 < 0 || header.Ver2 > C4GroupFileVer2 {
+		return &HeaderError{Field: "Ver2", Value: fmt.Sprint(header.Ver2)}
+	}
 	var buf bytes.Buffer
 	binary.Write(&buf, binary.LittleEndian, header)
-	memScramble(buf.Bytes())
+	if !hdr.Unscrambled {
+		memScramble(buf.Bytes())
+	}
 	_, err := io.Copy(cw.w, &buf)
 	//_, err := buf.WriteTo(cw.w)
 	cw.offset = 0
 	cw.haveHeader = true
 	cw.expectedEntries = hdr.Entries
+	cw.ver2 = header.Ver2
 	return err
 }
 
 // WriteHeader writes an entry header to the group.
+//
+// Writer never computes checksums. As entry headers precede the data, a
+// caller setting e.HasCRC must calculate e.CRC before writing the data:
+// with FileCRC for files and with ContentsCRC for child groups. Builder
+// does this with Builder.ComputeCRC.
 func (cw *Writer) WriteEntry(e *Entry) error {
 	if !cw.haveHeader {
 		return ErrNoHeader
@@ -113,6 +133,7 @@ func (cw *Writer) WriteEntry(e *Entry) error {
 		Offset: cw.offset,
 	}
 	privateEntry(&entry, e)
+	legacyEntry(&entry, cw.ver2)
 	cw.offset += int32(e.Size)
 	cw.expectedEntries--
 	err := binary.Write(cw.w, binary.LittleEndian, entry)